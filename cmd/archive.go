@@ -0,0 +1,115 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/prepuzio/ghligh/document"
+)
+
+// pdfs inside zip archives are named <archive>!/<entry>, like jar urls
+const archiveSeparator = "!/"
+
+// archivePath returns the path of a pdf inside archive
+func archivePath(archive string, entry string) string {
+	return archive + archiveSeparator + entry
+}
+
+// archivedEntry is a pdf inside a zip archive
+type archivedEntry struct {
+	archive string
+	entry   string
+}
+
+// scannedArchives maps the archivePath of the pdfs found inside .zip
+// archives by scans to their archivedEntry. Only those paths stand for
+// archived pdfs, any other one is a plain file even with archiveSeparator
+// in it (a directory named "Wow!").
+var scannedArchives sync.Map
+
+// splitArchivePath is the inverse of archivePath for the pdfs found by a
+// scan inside an archive, ok is false for paths of plain files
+func splitArchivePath(p string) (archive string, entry string, ok bool) {
+	v, ok := scannedArchives.Load(p)
+	if !ok {
+		return "", "", false
+	}
+	e := v.(archivedEntry)
+	return e.archive, e.entry, true
+}
+
+// maxArchivedPDFBytes caps the size of a pdf read from an archive, a small
+// zip can expand to far more than fits in memory
+const maxArchivedPDFBytes = 256 << 20
+
+// archivedPDFs lists the pdf files inside the zip archive, their paths are
+// then known to splitArchivePath
+func archivedPDFs(archive string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var pdfs []string
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || path.Ext(f.Name) != ".pdf" || isBackup(f.Name) {
+			continue
+		}
+		p := archivePath(archive, f.Name)
+		scannedArchives.Store(p, archivedEntry{archive: archive, entry: f.Name})
+		pdfs = append(pdfs, p)
+	}
+	return pdfs, nil
+}
+
+// readArchived returns the content of entry inside the zip archive
+func readArchived(archive string, entry string) ([]byte, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, err := r.Open(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", archive, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxArchivedPDFBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", archive, err)
+	}
+	if len(data) > maxArchivedPDFBytes {
+		return nil, fmt.Errorf("%s: %s is bigger than %d bytes", archive, entry, maxArchivedPDFBytes)
+	}
+	return data, nil
+}
+
+// openPDF opens a pdf file or a pdf inside a zip archive, the latter are
+// read only. password opens encrypted ones of both.
+func openPDF(p string, password string) (*document.GhlighDoc, error) {
+	archive, entry, ok := splitArchivePath(p)
+	if !ok {
+		return document.OpenWithPassword(p, password)
+	}
+
+	data, err := readArchived(archive, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := document.LoadWithPassword(data, p, password)
+	if err != nil {
+		return nil, err
+	}
+	doc.Archive = archive
+	return doc, nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitArchivePath(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a plain directory that looks like an archive path
+	touch(t, root, "Wow!/a.pdf")
+
+	f, err := os.Create(filepath.Join(root, "papers.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	if _, err := zw.Create("b.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := scanRel(t, root, scanOptions{archives: true})
+	want := []string{"Wow!/a.pdf", "papers.zip!/b.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("scan = %q, want %q", got, want)
+	}
+
+	if _, _, ok := splitArchivePath(filepath.Join(root, "Wow!/a.pdf")); ok {
+		t.Errorf("Wow!/a.pdf is a plain file, not an archived pdf")
+	}
+	archive, entry, ok := splitArchivePath(filepath.Join(root, "papers.zip!/b.pdf"))
+	if !ok || archive != filepath.Join(root, "papers.zip") || entry != "b.pdf" {
+		t.Errorf("splitArchivePath(papers.zip!/b.pdf) = %q, %q, %t", archive, entry, ok)
+	}
+}
+
+func TestModifiedSinceArchives(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, root, "old.zip")
+	touch(t, root, "new.pdf")
+	old := filepath.Join(root, "old.zip")
+	archived := archivePath(old, "a.pdf")
+	scannedArchives.Store(archived, archivedEntry{archive: old, entry: "a.pdf"})
+
+	since := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, since.Add(-time.Hour), since.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	pdfs := []string{archived, filepath.Join(root, "new.pdf"), filepath.Join(root, "gone.pdf")}
+	got := modifiedSince(pdfs, since)
+	want := []string{filepath.Join(root, "new.pdf")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("modifiedSince = %q, want %q", got, want)
+	}
+
+	if err := os.Chtimes(old, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	got = modifiedSince(pdfs, since)
+	want = []string{archived, filepath.Join(root, "new.pdf")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("modifiedSince = %q, want %q", got, want)
+	}
+}
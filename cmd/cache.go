@@ -0,0 +1,119 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prepuzio/ghligh/document"
+)
+
+// exportCache keeps the export of every pdf on disk, one file per pdf, so
+// that files that didn't change since the last export are not opened
+// again. A nil *exportCache is a disabled cache.
+type exportCache struct {
+	dir string
+}
+
+// cacheVersion changes when entries written by older versions of ghligh
+// miss something, they are then read again
+const cacheVersion = 8
+
+type cacheEntry struct {
+	Version  int       `json:"version"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	WithText bool      `json:"with_text"`
+	// OCRLang is the language of --ocr, "" without it
+	OCRLang  string             `json:"ocr_lang,omitempty"`
+	HashMode document.HashMode  `json:"hash_mode"`
+	Doc      document.GhlighDoc `json:"doc"`
+}
+
+// openExportCache returns the cache in the user cache directory
+// (~/.cache/ghligh/export on linux), creating it if needed
+func openExportCache() (*exportCache, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(base, "ghligh", "export")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &exportCache{dir: dir}, nil
+}
+
+func (c *exportCache) entryPath(path string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(path))))
+}
+
+// get returns the cached export of path, entries are only valid for the
+// same size, modification time and export options
+func (c *exportCache) get(path string, info os.FileInfo, withText bool, ocrLang string, mode document.HashMode) (document.GhlighDoc, bool) {
+	if c == nil {
+		return document.GhlighDoc{}, false
+	}
+
+	data, err := os.ReadFile(c.entryPath(path))
+	if err != nil {
+		return document.GhlighDoc{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return document.GhlighDoc{}, false
+	}
+
+	if entry.Version != cacheVersion || entry.Path != path || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) ||
+		entry.WithText != withText || entry.OCRLang != ocrLang || entry.HashMode != mode || entry.Doc.Version != document.FormatVersion {
+		return document.GhlighDoc{}, false
+	}
+	return entry.Doc, true
+}
+
+// put stores the export of path, errors only cost a cache miss next time
+// so they are ignored
+func (c *exportCache) put(path string, info os.FileInfo, withText bool, ocrLang string, mode document.HashMode, doc document.GhlighDoc) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(cacheEntry{
+		Version:  cacheVersion,
+		Path:     path,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		WithText: withText,
+		OCRLang:  ocrLang,
+		HashMode: mode,
+		Doc:      doc,
+	})
+	if err != nil {
+		return
+	}
+
+	// write and rename so concurrent exports never read half an entry
+	tempFile, err := os.CreateTemp(c.dir, ".entry_*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if cerr := tempFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	os.Rename(tempFile.Name(), c.entryPath(path))
+}
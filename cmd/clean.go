@@ -0,0 +1,161 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "remove highlights listed in a json file",
+	Long: `
+	ghligh clean foo.pdf bar.pdf ... [--root dir] [--from fnord.json] [-0] [--save=false] [-i] [--fuzzy] [--backup] [--password secret]
+
+	undoes ghligh import: removes from foo.pdf bar.pdf etc... the highlights
+	listed in the files specified with the --from flag (same format as the
+	export), an annotation is removed if it has the same page, subtype and
+	position as one in the json
+
+	--root will also clean every pdf found recursively inside dir, it can
+	be repeated
+
+	if -0 is set ghligh will read json from stdin
+
+	--save=false will run without saving documents, it will just tell you how
+	many annotations would be removed
+
+	--fuzzy matches documents without a matching hash by title, like
+	ghligh import --fuzzy, --backup, --password and --save-retries work
+	like in ghligh import
+
+	a json summary of what was removed is printed to stdout, -i will indent it
+`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && len(importRoots) == 0 {
+			cmd.Help()
+			return
+		}
+
+		stdin, err := cmd.Flags().GetBool("stdin")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		save, err := cmd.Flags().GetBool("save")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		fuzzy, err := cmd.Flags().GetBool("fuzzy")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if stdin == false && len(inputFiles) == 0 {
+			fmt.Fprintf(os.Stderr, "no highlights to remove I am not doing anything\n")
+			return
+		}
+
+		ia := newImportedAnnots()
+		loaded := loadImportFiles(ia, inputFiles)
+		if stdin {
+			if err := loadImportedAnnots(ia, os.Stdin); err != nil {
+				fmt.Fprintf(os.Stderr, "stdin: %v\n", err)
+				loaded = false
+			}
+		}
+		if !loaded {
+			os.Exit(1)
+		}
+
+		files := args
+		if len(importRoots) > 0 {
+			pdfs, err := scanRoots(context.Background(), importRoots, cliScanOptions())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			files = append(files, pdfs...)
+		}
+
+		backup, err := cmd.Flags().GetBool("backup")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		saveRetries, err := cmd.Flags().GetInt("save-retries")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		im := &importer{
+			save:        save,
+			fuzzy:       fuzzy,
+			saveRetries: saveRetries,
+			remove:      true,
+			backup:      backup,
+			password:    password,
+			concurrency: runtime.NumCPU(),
+		}
+		summary := im.importAll(files, ia)
+
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(summary, "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(summary)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(jsonBytes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().BoolP("stdin", "0", false, "read json from stdin")
+	cleanCmd.Flags().Bool("save", true, "save the files the annotations were removed from")
+	cleanCmd.Flags().StringArrayVarP(&inputFiles, "from", "f", []string{}, "files listing the annots to remove")
+	cleanCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories of --root")
+	cleanCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "only consider files of --root matching this glob pattern (repeatable)")
+	cleanCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "skip paths of --root matching this glob pattern (repeatable)")
+	cleanCmd.Flags().StringArrayVarP(&importRoots, "root", "r", []string{}, "also clean every pdf found recursively in this directory (repeatable)")
+	cleanCmd.Flags().BoolP("indent", "i", false, "indent the json summary")
+	cleanCmd.Flags().String("password", "", "password of encrypted pdfs")
+	cleanCmd.Flags().Bool("backup", false, "copy every pdf to <name>.ghligh-<time>.bak.pdf before saving it")
+	cleanCmd.Flags().Int("save-retries", 3, "how many times to retry saving a file after a transient error")
+	cleanCmd.Flags().Bool("fuzzy", false, "match documents by similar title when no hash matches")
+
+	cleanCmd.ValidArgsFunction = completePDFs
+	registerFileCompletion(cleanCmd, "from", "json")
+	registerFileCompletion(cleanCmd, "root", "")
+}
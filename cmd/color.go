@@ -0,0 +1,185 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+	"golang.org/x/term"
+)
+
+// how many colors the terminal can show
+type colorDepth int
+
+const (
+	noColor colorDepth = iota
+	color16
+	color256
+	trueColor
+)
+
+// terminalColorDepth guesses the colors supported by the terminal on f,
+// noColor if it is not a terminal or NO_COLOR is set
+func terminalColorDepth(f *os.File) colorDepth {
+	if !term.IsTerminal(int(f.Fd())) || os.Getenv("NO_COLOR") != "" {
+		return noColor
+	}
+
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return trueColor
+	}
+
+	t := os.Getenv("TERM")
+	switch {
+	case t == "dumb":
+		return noColor
+	case strings.Contains(t, "256color"):
+		return color256
+	}
+	return color16
+}
+
+// rgb of a poppler color, 8 bits per channel
+func rgb(c poppler.Color) (r, g, b int) {
+	return int(c.R >> 8), int(c.G >> 8), int(c.B >> 8)
+}
+
+// ansi256 returns the closest color of the xterm 256 colors 6x6x6 cube
+// or grayscale ramp
+func ansi256(r, g, b int) int {
+	if r == g && g == b {
+		if r < 8 {
+			return 16
+		}
+		if r > 248 {
+			return 231
+		}
+		return 232 + (r-8)*24/241
+	}
+
+	level := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	return 16 + 36*level(r) + 6*level(g) + level(b)
+}
+
+// the 16 standard colors as usually rendered by terminals
+var ansi16Palette = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// ansi16 returns the index of the closest of the 16 standard colors
+func ansi16(r, g, b int) int {
+	best, bestDist := 0, -1
+	for i, p := range ansi16Palette {
+		dr, dg, db := r-p[0], g-p[1], b-p[2]
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// colorize sets the background of text to c, with a black or white
+// foreground depending on how light c is. Every line is colored on its own
+// so that indentation is left alone.
+func colorize(text string, c *poppler.Color, depth colorDepth) string {
+	if c == nil || depth == noColor {
+		return text
+	}
+
+	r, g, b := rgb(*c)
+
+	var bg string
+	switch depth {
+	case trueColor:
+		bg = fmt.Sprintf("48;2;%d;%d;%d", r, g, b)
+	case color256:
+		bg = fmt.Sprintf("48;5;%d", ansi256(r, g, b))
+	default:
+		i := ansi16(r, g, b)
+		if i < 8 {
+			bg = fmt.Sprintf("%d", 40+i)
+		} else {
+			bg = fmt.Sprintf("%d", 100+i-8)
+		}
+	}
+
+	fg := "30"
+	if 299*r+587*g+114*b < 128000 {
+		fg = "97"
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		prefix := line[:len(line)-len(trimmed)]
+		lines[i] = fmt.Sprintf("%s\x1b[%s;%sm%s\x1b[0m", prefix, fg, bg, trimmed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colors accepted by name by --color, the usual highlighter colors
+var namedColors = map[string]string{
+	"yellow": "#ffff00",
+	"red":    "#ff0000",
+	"green":  "#00ff00",
+	"blue":   "#0000ff",
+	"cyan":   "#00ffff",
+	"orange": "#ffa500",
+	"purple": "#800080",
+	"pink":   "#ff69b4",
+	"gray":   "#808080",
+	"black":  "#000000",
+}
+
+// colorNames returns the names of namedColors, sorted
+func colorNames() []string {
+	var names []string
+	for name := range namedColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseColor parses #rrggbb, rrggbb, #rgb or one of namedColors
+func parseColor(s string) (poppler.Color, error) {
+	hex := strings.ToLower(strings.TrimSpace(s))
+	if named, ok := namedColors[hex]; ok {
+		hex = named
+	}
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	var r, g, b int
+	if len(hex) != 6 {
+		return poppler.Color{}, fmt.Errorf("invalid color %q, use #rrggbb or a name like yellow", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &r, &g, &b); err != nil {
+		return poppler.Color{}, fmt.Errorf("invalid color %q, use #rrggbb or a name like yellow", s)
+	}
+	// poppler colors are 16 bits per channel
+	return poppler.Color{R: r * 0x101, G: g * 0x101, B: b * 0x101}, nil
+}
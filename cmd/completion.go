@@ -0,0 +1,53 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// cobra already adds "ghligh completion bash|zsh|fish|powershell", these
+// are the completions of flag values and arguments it can't guess
+
+// completePDFs completes arguments with pdf files
+func completePDFs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"pdf"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeJSON completes arguments with json files
+func completeJSON(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"json"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+func hashModeNames() []string {
+	var names []string
+	for _, mode := range document.HashModes {
+		names = append(names, string(mode))
+	}
+	return names
+}
+
+// registerCompletion makes flag complete with the given values, it panics
+// if the flag doesn't exist so that typos are caught at startup
+func registerCompletion(cmd *cobra.Command, flag string, values ...string) {
+	err := cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// registerFileCompletion makes flag complete with files of type ext, or
+// with directories if ext is ""
+func registerFileCompletion(cmd *cobra.Command, flag string, ext string) {
+	var err error
+	if ext == "" {
+		err = cmd.MarkFlagDirname(flag)
+	} else {
+		err = cmd.MarkFlagFilename(flag, ext)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var configFile string
+
+// config holds flag values by command name, "" is the top level whose
+// values apply to every command having the flag
+type config map[string]map[string][]string
+
+func (c config) add(section string, key string, value string) {
+	if c[section] == nil {
+		c[section] = make(map[string][]string)
+	}
+	c[section][key] = append(c[section][key], value)
+}
+
+// configValue unquotes a yaml scalar, unquoted values end at a comment
+func configValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// parseConfig reads the subset of yaml used by ghligh.yaml: "flag: value"
+// pairs at the top level or inside a section named after a command, with
+// lists written as "- value" lines below the flag name
+//
+//	concurrency: 4
+//	serve:
+//	  addr: ":8080"
+//	  exclude:
+//	    - drafts
+//
+// The indentation of the first line is the top level and is removed from
+// every line, so that the example of ghligh --help can be pasted as it is,
+// any other indentation must be spaces.
+func parseConfig(r io.Reader) (config, error) {
+	cfg := make(config)
+
+	var section string
+	// last "key:" without a value, a section or a list
+	var header, headerSection string
+	headerIndent := -1
+	// indentation of the first line
+	var margin string
+	first := true
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if first {
+			margin = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			first = false
+		}
+		line, ok := strings.CutPrefix(line, margin)
+		if !ok {
+			return nil, fmt.Errorf("line %d: less indented than the first line", n)
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", n)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if item, ok := strings.CutPrefix(text, "- "); ok {
+			if header == "" || indent <= headerIndent {
+				return nil, fmt.Errorf("line %d: list item outside of a list", n)
+			}
+			value, err := configValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			cfg.add(headerSection, header, value)
+			continue
+		}
+
+		key, rest, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		key = strings.TrimSpace(key)
+		rest = strings.TrimSpace(rest)
+
+		if indent == 0 {
+			section = ""
+		} else if section == "" {
+			if header == "" || headerIndent != 0 {
+				return nil, fmt.Errorf("line %d: unexpected indentation", n)
+			}
+			section = header
+		}
+
+		if rest == "" {
+			header, headerSection, headerIndent = key, section, indent
+			continue
+		}
+		header, headerIndent = "", -1
+
+		value, err := configValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		cfg.add(section, key, value)
+	}
+
+	return cfg, scanner.Err()
+}
+
+// loadConfig reads --config, or else ghligh.yaml from the current
+// directory or the user config directory, no file is not an error
+func loadConfig() (config, error) {
+	paths := []string{configFile}
+	if configFile == "" {
+		paths = []string{"ghligh.yaml"}
+		if dir, err := os.UserConfigDir(); err == nil {
+			paths = append(paths, filepath.Join(dir, "ghligh", "ghligh.yaml"))
+		}
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) && configFile == "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		cfg, err := parseConfig(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return cfg, nil
+	}
+
+	return config{}, nil
+}
+
+// envName is the environment variable overriding flag, e.g.
+// GHLIGH_AUTH_TOKEN for --auth-token
+func envName(flag string) string {
+	return "GHLIGH_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyConfig sets the flags of cmd not given on the command line from
+// the environment, or else from cfg. Command line flags win over
+// environment variables which win over the config file. List flags take
+// comma separated environment values.
+func applyConfig(cmd *cobra.Command, cfg config) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" || f.Name == "config" {
+			return
+		}
+
+		var values []string
+		if env, ok := os.LookupEnv(envName(f.Name)); ok {
+			values = []string{env}
+			if strings.HasSuffix(f.Value.Type(), "Array") || strings.HasSuffix(f.Value.Type(), "Slice") {
+				values = strings.Split(env, ",")
+			}
+		} else if v, ok := cfg[cmd.Name()][f.Name]; ok {
+			values = v
+		} else {
+			values = cfg[""][f.Name]
+		}
+
+		for _, v := range values {
+			if err = cmd.Flags().Set(f.Name, v); err != nil {
+				err = fmt.Errorf("invalid value %q for --%s: %v", v, f.Name, err)
+				return
+			}
+		}
+	})
+	return err
+}
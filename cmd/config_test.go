@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want config
+	}{
+		{
+			name: "top level and sections",
+			yaml: "concurrency: 4\nserve:\n  addr: \":8080\"\n  root: /home/me/papers\n",
+			want: config{
+				"":      {"concurrency": {"4"}},
+				"serve": {"addr": {":8080"}, "root": {"/home/me/papers"}},
+			},
+		},
+		{
+			name: "comments",
+			yaml: "# ghligh.yaml\nconcurrency: 4 # one per core\n\n  # indented comment\nserve:\n  auth-token: 'a # b'\n  addr: \"#1\"\n",
+			want: config{
+				"":      {"concurrency": {"4"}},
+				"serve": {"auth-token": {"a # b"}, "addr": {"#1"}},
+			},
+		},
+		{
+			name: "lists",
+			yaml: "exclude:\n  - drafts\n  - \"*.tmp.pdf\"\nserve:\n  include:\n    - papers\n    - 'it''s'\n  addr: :8080\n",
+			want: config{
+				"":      {"exclude": {"drafts", "*.tmp.pdf"}},
+				"serve": {"include": {"papers", "it's"}, "addr": {":8080"}},
+			},
+		},
+		{
+			name: "repeated keys",
+			yaml: "exclude: drafts\nexclude: old\nserve:\n  addr: :8080\nserve:\n  addr: :9090\n",
+			want: config{
+				"":      {"exclude": {"drafts", "old"}},
+				"serve": {"addr": {":8080", ":9090"}},
+			},
+		},
+		{
+			name: "help example",
+			yaml: "\t\tconcurrency: 4\n\t\tserve:\n\t\t  addr: \":8080\"\n\t\t  root: /home/me/papers\n\t\t  exclude:\n\t\t    - drafts\n",
+			want: config{
+				"":      {"concurrency": {"4"}},
+				"serve": {"addr": {":8080"}, "root": {"/home/me/papers"}, "exclude": {"drafts"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(strings.NewReader(tt.yaml))
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConfig = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"tab indentation", "serve:\n\taddr: :8080\n", "line 2: indent with spaces, not tabs"},
+		{"tab after spaces", "serve:\n  \taddr: :8080\n", "line 2: indent with spaces, not tabs"},
+		{"less indented than the first line", "  concurrency: 4\nserve:\n", "line 2: less indented than the first line"},
+		{"indented without a section", "concurrency: 4\n  addr: :8080\n", "line 2: unexpected indentation"},
+		{"list item outside of a list", "- drafts\n", "line 1: list item outside of a list"},
+		{"list item not indented", "exclude:\n- drafts\n", "line 2: list item outside of a list"},
+		{"missing colon", "concurrency 4\n", "line 1: expected \"key: value\""},
+		{"unterminated string", "addr: 'localhost\n", "line 1: unterminated string 'localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig(strings.NewReader(tt.yaml))
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseConfig error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
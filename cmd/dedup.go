@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// dedupFile merges the overlapping annotations of the pdf at path, it
+// returns how many were merged
+func dedupFile(path string, threshold float64, password string, save bool, backup bool) (int, error) {
+	if _, _, ok := splitArchivePath(path); ok && save {
+		return 0, document.ErrReadOnly
+	}
+
+	doc, err := openPDF(path, password)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+
+	merged := doc.Dedup(threshold)
+	if merged == 0 || !save {
+		return merged, nil
+	}
+
+	if backup {
+		if _, err := backupFile(path); err != nil {
+			return merged, fmt.Errorf("could not back up: %v", err)
+		}
+	}
+	if _, err := doc.Save(); err != nil {
+		return merged, err
+	}
+	return merged, nil
+}
+
+// dedupCmd represents the dedup command
+var dedupCmd = &cobra.Command{
+	Use:   "dedup",
+	Short: "merge overlapping copies of the same highlight",
+	Long: `
+	ghligh dedup foo.pdf bar.pdf ... [--threshold 0.9] [--save=false] [--backup] [--password secret]
+
+	merges the highlights, underlines, squiggles and strike outs of the same
+	page, subtype and color that overlap, like the copies left behind by
+	editing a pdf again and again, keeping the largest of them. The note
+	of a merged annotation is moved to the kept one if it has none,
+	annotations with different notes are left alone
+
+	--threshold is how much of the smaller annotation the other must cover
+	to be merged, from 0 to 1 (by default 0.9, nearly identical)
+
+	--save=false only tells how many annotations would be merged, --backup
+	copies every pdf to <name>.ghligh-<time>.bak.pdf before saving it (see
+	ghligh import) and --password opens encrypted pdfs
+
+	for every file the number of annotations merged is printed
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			return
+		}
+
+		threshold, err := cmd.Flags().GetFloat64("threshold")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if threshold <= 0 || threshold > 1 {
+			fmt.Fprintf(os.Stderr, "invalid threshold %v, it must be more than 0 and at most 1\n", threshold)
+			os.Exit(1)
+		}
+
+		save, err := cmd.Flags().GetBool("save")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		backup, err := cmd.Flags().GetBool("backup")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		failed := false
+		for _, arg := range args {
+			merged, err := dedupFile(arg, threshold, password, save, backup)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", arg, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: %d annotations merged\n", arg, merged)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dedupCmd)
+
+	dedupCmd.Flags().Float64("threshold", document.DefaultDedupThreshold, "share of the smaller annotation that must overlap to merge them")
+	dedupCmd.Flags().Bool("save", true, "save the deduplicated files")
+	dedupCmd.Flags().Bool("backup", false, "copy every pdf to <name>.ghligh-<time>.bak.pdf before saving it")
+	dedupCmd.Flags().String("password", "", "password of encrypted pdfs")
+
+	dedupCmd.ValidArgsFunction = completePDFs
+}
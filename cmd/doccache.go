@@ -0,0 +1,268 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prepuzio/ghligh/document"
+)
+
+// docCache keeps in memory the hashes and exports of the last used pdfs,
+// so that the export, diff, import sequence of a client doesn't open and
+// hash the same files again. It is shared by every handler of the server.
+//
+// An entry is only valid for the size and modification time of the file
+// (of its archive for pdfs inside one) it was computed with, any other
+// stat drops it on the next lookup. Saves through the server call forget
+// for the saved file, since a save in the same second keeping the size
+// would look unchanged; files changed by anything else are only caught by
+// their stat. Entries past max are dropped least recently used first.
+// Exports are copied out (see copyExport) so handlers can filter them.
+//
+// A nil *docCache is a disabled cache.
+type docCache struct {
+	mu sync.Mutex
+	// maximum number of files kept
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type docExportKey struct {
+	withText bool
+	// "" without ocr, see exporter.ocrLang
+	ocrLang string
+	mode    document.HashMode
+}
+
+type docCacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+	hashes  map[document.HashMode]string
+	exports map[docExportKey]document.GhlighDoc
+}
+
+// newDocCache returns a cache of at most max files, nil if max < 1
+func newDocCache(max int) *docCache {
+	if max < 1 {
+		return nil
+	}
+	return &docCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// docCacheKey returns the absolute path of path and the file whose size and
+// modification time tell if entries are still valid, pdfs inside archives
+// change with their archive
+func docCacheKey(path string) (string, os.FileInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	statPath := abs
+	if archive, _, ok := splitArchivePath(abs); ok {
+		statPath = archive
+	}
+	info, err := os.Stat(statPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return abs, info, nil
+}
+
+// entry returns the valid entry of abs, creating it when create is set.
+// c.mu must be held.
+func (c *docCache) entry(abs string, info os.FileInfo, create bool) *docCacheEntry {
+	if el, ok := c.entries[abs]; ok {
+		entry := el.Value.(*docCacheEntry)
+		if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			c.order.MoveToFront(el)
+			return entry
+		}
+		c.order.Remove(el)
+		delete(c.entries, abs)
+	}
+	if !create {
+		return nil
+	}
+
+	entry := &docCacheEntry{
+		path:    abs,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		hashes:  make(map[document.HashMode]string),
+		exports: make(map[docExportKey]document.GhlighDoc),
+	}
+	c.entries[abs] = c.order.PushFront(entry)
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*docCacheEntry).path)
+	}
+	return entry
+}
+
+// hash returns the cached hash of the file at abs
+func (c *docCache) hash(abs string, info os.FileInfo, mode document.HashMode) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(abs, info, false)
+	if entry == nil {
+		return "", false
+	}
+	hash, ok := entry.hashes[mode]
+	return hash, ok
+}
+
+func (c *docCache) putHash(abs string, info os.FileInfo, mode document.HashMode, hash string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entry(abs, info, true).hashes[mode] = hash
+}
+
+// export returns a copy of the cached export of the file at abs, callers
+// are free to modify it
+func (c *docCache) export(abs string, info os.FileInfo, withText bool, ocrLang string, mode document.HashMode) (document.GhlighDoc, bool) {
+	if c == nil {
+		return document.GhlighDoc{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(abs, info, false)
+	if entry == nil {
+		return document.GhlighDoc{}, false
+	}
+	doc, ok := entry.exports[docExportKey{withText, ocrLang, mode}]
+	if !ok {
+		return document.GhlighDoc{}, false
+	}
+	return copyExport(doc), true
+}
+
+// putExport stores doc, which must not be modified afterwards, as the
+// export of the file at abs
+func (c *docCache) putExport(abs string, info os.FileInfo, withText bool, ocrLang string, mode document.HashMode, doc document.GhlighDoc) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(abs, info, true)
+	entry.exports[docExportKey{withText, ocrLang, mode}] = doc
+	entry.hashes[mode] = doc.HashBuffer
+}
+
+// forget drops what is known about path, called after saving it: a save
+// within the same second keeping the size would look unchanged otherwise
+func (c *docCache) forget(path string) {
+	if c == nil {
+		return
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[abs]; ok {
+		c.order.Remove(el)
+		delete(c.entries, abs)
+	}
+}
+
+// copyExport copies the parts of an export that exporter.export modifies
+func copyExport(doc document.GhlighDoc) document.GhlighDoc {
+	annots := make(document.AnnotsMap, len(doc.AnnotsBuffer))
+	for page, pageAnnots := range doc.AnnotsBuffer {
+		annots[page] = append([]document.AnnotJSON(nil), pageAnnots...)
+	}
+	doc.AnnotsBuffer = annots
+
+	if doc.PageSizes != nil {
+		sizes := make(map[int]document.PageSize, len(doc.PageSizes))
+		for page, size := range doc.PageSizes {
+			sizes[page] = size
+		}
+		doc.PageSizes = sizes
+	}
+	return doc
+}
+
+// lookupHash is importedAnnots.lookupHash for the file at path, using and
+// filling the cached hashes of c
+func (c *docCache) lookupHash(ia *importedAnnots, doc *document.GhlighDoc, path string) (string, document.AnnotsMap, error) {
+	if c == nil {
+		return ia.lookupHash(doc)
+	}
+	abs, info, err := docCacheKey(path)
+	if err != nil {
+		return ia.lookupHash(doc)
+	}
+
+	for _, mode := range document.HashModes {
+		if !ia.modes[mode] {
+			continue
+		}
+
+		hash, ok := c.hash(abs, info, mode)
+		if !ok {
+			hash, err = doc.HashDocMode(mode)
+			if err != nil {
+				return "", nil, err
+			}
+			c.putHash(abs, info, mode, hash)
+		}
+		if am := ia.get(hash); len(am) > 0 {
+			return hash, am, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// knownMiss reports whether the cached hashes of path, for every hash mode
+// of the import, match no imported document, the file then doesn't need to
+// be opened
+func (c *docCache) knownMiss(ia *importedAnnots, path string) bool {
+	if c == nil {
+		return false
+	}
+	abs, info, err := docCacheKey(path)
+	if err != nil {
+		return false
+	}
+
+	checked := 0
+	for _, mode := range document.HashModes {
+		if !ia.modes[mode] {
+			continue
+		}
+		hash, ok := c.hash(abs, info, mode)
+		if !ok || len(ia.get(hash)) > 0 {
+			return false
+		}
+		checked++
+	}
+	return checked > 0
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// doctorReport is what ghligh doctor finds out about a pdf
+type doctorReport struct {
+	File      string `json:"file"`
+	Opens     bool   `json:"opens"`
+	Encrypted bool   `json:"encrypted"`
+	// Error is why the file can't be opened
+	Error string `json:"error,omitempty"`
+	Pages int    `json:"pages"`
+	// TextPages have a text layer, see document.GhlighDoc.TextLayerPages
+	TextPages   int            `json:"textPages"`
+	Annotations int            `json:"annotations"`
+	BySubtype   map[string]int `json:"bySubtype"`
+	// PageErrors are the pages whose annotations can't be read
+	PageErrors []document.PageError `json:"pageErrors,omitempty"`
+	// Hashes by hash mode, HashErrors for the modes that failed
+	Hashes     map[string]string `json:"hashes"`
+	HashErrors map[string]string `json:"hashErrors,omitempty"`
+}
+
+// diagnose opens path, with password if it is encrypted, and checks what
+// export and import rely on
+func diagnose(path string, password string) (report doctorReport) {
+	report.File = path
+	report.BySubtype = make(map[string]int)
+	report.Hashes = make(map[string]string)
+	defer func() {
+		if r := recover(); r != nil {
+			report.Error = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+
+	doc, err := openPDF(path, "")
+	if errors.Is(err, document.ErrEncrypted) {
+		report.Encrypted = true
+		if password != "" {
+			doc, err = openPDF(path, password)
+		}
+	}
+	if err != nil {
+		report.Error = openErrorReason(err)
+		return report
+	}
+	defer doc.Close()
+	report.Opens = true
+
+	report.Pages = doc.GetNPages()
+	report.TextPages = doc.TextLayerPages()
+
+	for _, annots := range doc.GetAnnotsBuffer() {
+		for _, annot := range annots {
+			report.Annotations++
+			report.BySubtype[annot.Subtype]++
+		}
+	}
+	report.PageErrors = doc.PageErrors
+
+	for _, mode := range document.HashModes {
+		hash, err := doc.HashDocMode(mode)
+		if err != nil {
+			if report.HashErrors == nil {
+				report.HashErrors = make(map[string]string)
+			}
+			report.HashErrors[string(mode)] = err.Error()
+			continue
+		}
+		report.Hashes[string(mode)] = hash
+	}
+
+	return report
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func (report doctorReport) print() {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\n", report.File)
+	fmt.Fprintf(tw, "  opens\t%s\n", yesNo(report.Opens))
+	fmt.Fprintf(tw, "  encrypted\t%s\n", yesNo(report.Encrypted))
+	if report.Error != "" {
+		fmt.Fprintf(tw, "  error\t%s\n", report.Error)
+		tw.Flush()
+		return
+	}
+
+	fmt.Fprintf(tw, "  pages\t%d\n", report.Pages)
+	textLayer := fmt.Sprintf("%d of %d pages", report.TextPages, report.Pages)
+	if report.TextPages < report.Pages {
+		textLayer += ", use export --ocr for the text of the others"
+	}
+	fmt.Fprintf(tw, "  text layer\t%s\n", textLayer)
+	fmt.Fprintf(tw, "  annotations\t%d\n", report.Annotations)
+	for _, subtype := range sortedCounts(report.BySubtype) {
+		fmt.Fprintf(tw, "    %s\t%d\n", subtype, report.BySubtype[subtype])
+	}
+	for _, pe := range report.PageErrors {
+		fmt.Fprintf(tw, "  page %d\tcan't be read: %s\n", pe.Page+1, pe.Error)
+	}
+	for _, mode := range document.HashModes {
+		if hash, ok := report.Hashes[string(mode)]; ok {
+			fmt.Fprintf(tw, "  %s hash\t%s\n", mode, hash)
+		} else {
+			fmt.Fprintf(tw, "  %s hash\terror: %s\n", mode, report.HashErrors[string(mode)])
+		}
+	}
+	tw.Flush()
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "diagnose why a pdf can't be exported or imported [json]",
+	Long: `
+	ghligh doctor foo.pdf bar.pdf ... [--password secret] [--json] [-i]
+
+	tells for every pdf whether it opens, whether it is encrypted (and
+	opens with --password), how many pages have a text layer (the
+	highlighted text of the others needs export --ocr), how many
+	annotations of each subtype it has and its hash in every hash mode
+
+	exits with 1 if one of the files can't be opened
+
+	if --json is set the output will be in json format, -i will indent it
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			return
+		}
+
+		useJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		var reports []doctorReport
+		failed := false
+		for _, arg := range args {
+			report := diagnose(arg, password)
+			if !report.Opens {
+				failed = true
+			}
+			reports = append(reports, report)
+		}
+
+		if useJSON {
+			var jsonBytes []byte
+			if indent {
+				jsonBytes, err = json.MarshalIndent(reports, "", "	")
+			} else {
+				jsonBytes, err = json.Marshal(reports)
+			}
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(jsonBytes))
+		} else {
+			for i, report := range reports {
+				if i > 0 {
+					fmt.Println()
+				}
+				report.print()
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolP("json", "j", false, "print the diagnosis as json")
+	doctorCmd.Flags().BoolP("indent", "i", false, "indent the json output")
+	doctorCmd.Flags().String("password", "", "password of encrypted pdfs")
+
+	doctorCmd.ValidArgsFunction = completePDFs
+}
@@ -4,16 +4,588 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prepuzio/ghligh/document"
+	"github.com/prepuzio/ghligh/go-poppler"
 	"github.com/spf13/cobra"
 )
 
 var outputFiles []string
 
+var exportRoots []string
+
+// shared by export and import
+var followSymlinks bool
+var scanInclude []string
+var scanExclude []string
+var scanArchives bool
+
+// cliScanOptions returns the scan options set on the command line, exiting
+// on invalid ones
+func cliScanOptions() scanOptions {
+	for _, patterns := range [][]string{scanInclude, scanExclude} {
+		if err := validatePatterns(patterns); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	return scanOptions{
+		followSymlinks: followSymlinks,
+		include:        scanInclude,
+		exclude:        scanExclude,
+		archives:       scanArchives,
+	}
+}
+
+// pageRange is an inclusive range of pages numbered from 1
+type pageRange struct {
+	from, to int
+}
+
+// pageRanges selects pages of a document, nil selects every page
+type pageRanges []pageRange
+
+// parsePageRanges parses page ranges like "5-20,33", pages are numbered
+// from 1
+func parsePageRanges(s string) (pageRanges, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ranges pageRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+
+		var r pageRange
+		var err error
+		if r.from, err = strconv.Atoi(from); err != nil {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		r.to = r.from
+		if isRange {
+			if r.to, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+
+		if r.from < 1 || r.to < r.from {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// contains reports whether the page with index i (from 0) is selected
+func (pr pageRanges) contains(i int) bool {
+	if pr == nil {
+		return true
+	}
+	for _, r := range pr {
+		if i+1 >= r.from && i+1 <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if pr selects none of the pages of a document
+// with nPages pages, ranges going past its end are cut to its last page
+func (pr pageRanges) check(nPages int) error {
+	if pr == nil {
+		return nil
+	}
+	first := pr[0].from
+	for _, r := range pr {
+		if r.from <= nPages {
+			return nil
+		}
+		first = min(first, r.from)
+	}
+	return fmt.Errorf("page %d out of range, document has %d pages", first, nPages)
+}
+
+func (pr pageRanges) filter(am document.AnnotsMap) document.AnnotsMap {
+	if pr == nil {
+		return am
+	}
+
+	filtered := make(document.AnnotsMap)
+	for page, annots := range am {
+		if pr.contains(page) {
+			filtered[page] = annots
+		}
+	}
+	return filtered
+}
+
+// subtypeFilter keeps the annotations of some subtypes, nil keeps every one
+type subtypeFilter map[string]bool
+
+func parseSubtypeFilter(values []string) (subtypeFilter, error) {
+	var sf subtypeFilter
+	for _, value := range values {
+		subtype, err := document.ParseSubtype(value)
+		if err != nil {
+			return nil, err
+		}
+		if sf == nil {
+			sf = make(subtypeFilter)
+		}
+		sf[subtype] = true
+	}
+	return sf, nil
+}
+
+// matches reports whether subtype is one of the filter, annotations from
+// exports without subtypes are highlights
+func (sf subtypeFilter) matches(subtype string) bool {
+	if subtype == "" {
+		subtype = "Highlight"
+	}
+	return sf[subtype]
+}
+
+func (sf subtypeFilter) filter(am document.AnnotsMap) document.AnnotsMap {
+	if sf == nil {
+		return am
+	}
+
+	filtered := make(document.AnnotsMap)
+	for page, annots := range am {
+		var kept []document.AnnotJSON
+		for _, annot := range annots {
+			if sf.matches(annot.Subtype) {
+				kept = append(kept, annot)
+			}
+		}
+		if len(kept) > 0 {
+			filtered[page] = kept
+		}
+	}
+	return filtered
+}
+
+// colors closer than this (euclidean distance over 8 bit channels) are
+// the same for colorFilter: pdf viewers don't agree on the exact shade of
+// their highlighters
+const colorTolerance = 64
+
+// colorFilter keeps the annotations of some colors, nil keeps every one
+type colorFilter []poppler.Color
+
+func parseColorFilter(values []string) (colorFilter, error) {
+	var cf colorFilter
+	for _, value := range values {
+		c, err := parseColor(value)
+		if err != nil {
+			return nil, err
+		}
+		cf = append(cf, c)
+	}
+	return cf, nil
+}
+
+// matches reports whether c is close to one of the colors, annotations
+// without a color never match
+func (cf colorFilter) matches(c *poppler.Color) bool {
+	if c == nil {
+		return false
+	}
+
+	r, g, b := rgb(*c)
+	for _, want := range cf {
+		wr, wg, wb := rgb(want)
+		dr, dg, db := r-wr, g-wg, b-wb
+		if dr*dr+dg*dg+db*db <= colorTolerance*colorTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf colorFilter) filter(am document.AnnotsMap) document.AnnotsMap {
+	if cf == nil {
+		return am
+	}
+
+	filtered := make(document.AnnotsMap)
+	for page, annots := range am {
+		var kept []document.AnnotJSON
+		for _, annot := range annots {
+			if cf.matches(annot.Color) {
+				kept = append(kept, annot)
+			}
+		}
+		if len(kept) > 0 {
+			filtered[page] = kept
+		}
+	}
+	return filtered
+}
+
+// exporter turns pdf files into the documents written by export, it is
+// shared by the export command and the /export endpoint
+type exporter struct {
+	concurrency int
+	// when done no more files are exported, nil never stops
+	ctx context.Context
+	// also extract the highlighted text
+	withText bool
+	// with withText, recognizes the text of scanned pages, see
+	// document.SetOCR
+	ocr document.OCRFunc
+	// language of ocr, part of the cache keys since it changes the text
+	ocrLang  string
+	hashMode document.HashMode
+	// only export annotations of these pages
+	pages pageRanges
+	// only export annotations of these colors
+	colors colorFilter
+	// only export annotations of these subtypes
+	subtypes subtypeFilter
+	// used to open encrypted pdfs
+	password string
+	// give up on files taking longer than this, 0 never does
+	fileTimeout time.Duration
+	// exports of unchanged files, nil disables caching
+	cache *exportCache
+	// in memory exports and hashes shared by the handlers of the server,
+	// looked up before cache
+	docs *docCache
+	// only keep the documents with these hashes, nil keeps every one
+	hashes map[string]bool
+	// when set file paths are written relative to the first of these
+	// directories containing them, see relativePath
+	relativeTo []string
+
+	// onProgress, when set, is called every time a file is processed
+	onProgress func(done, total int)
+	// onError, when set, is called for every file that can't be exported
+	onError func(path string, err error)
+}
+
+// relativePath returns path relative to the first of roots containing it,
+// with forward slashes so that exports are the same on every system. Paths
+// outside of every root are returned as they are.
+func relativePath(path string, roots []string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// the root is the file itself
+		if rel == "." {
+			rel = filepath.Base(abs)
+		}
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// openErrorReason tells in short why a pdf couldn't be opened
+func openErrorReason(err error) string {
+	if errors.Is(err, document.ErrEncrypted) {
+		return "encrypted"
+	}
+	return err.Error()
+}
+
+// exportSafe is export turning a panic (broken pdfs can trip the poppler
+// bindings) into an error, so one file doesn't stop the others
+func (e *exporter) exportSafe(path string) (doc document.GhlighDoc, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return e.export(path)
+}
+
+// exportTimeout is exportSafe giving up after e.fileTimeout with a
+// *fileTimeoutError
+func (e *exporter) exportTimeout(path string) (document.GhlighDoc, error) {
+	type result struct {
+		doc document.GhlighDoc
+		err error
+	}
+	res, err := withFileTimeout(e.fileTimeout, func(ctx context.Context) result {
+		doc, err := e.exportSafe(path)
+		return result{doc, err}
+	})
+	if err != nil {
+		return document.GhlighDoc{}, err
+	}
+	return res.doc, res.err
+}
+
+func (e *exporter) export(path string) (document.GhlighDoc, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return document.GhlighDoc{}, err
+	}
+
+	// pdfs inside archives change with their archive
+	statPath := abs
+	if archive, _, ok := splitArchivePath(abs); ok {
+		statPath = archive
+	}
+	info, err := os.Stat(statPath)
+	if err != nil {
+		return document.GhlighDoc{}, err
+	}
+
+	ocrLang := ""
+	if e.ocr != nil {
+		ocrLang = e.ocrLang
+	}
+	doc, ok := e.docs.export(abs, info, e.withText, ocrLang, e.hashMode)
+	if !ok {
+		doc, ok = e.cache.get(abs, info, e.withText, ocrLang, e.hashMode)
+		if !ok {
+			doc, err = e.read(path)
+			if err != nil {
+				return document.GhlighDoc{}, err
+			}
+			e.cache.put(abs, info, e.withText, ocrLang, e.hashMode, doc)
+		}
+		e.docs.putExport(abs, info, e.withText, ocrLang, e.hashMode, doc)
+		doc = copyExport(doc)
+	}
+	// the cached file name may come from another relative path
+	doc.Path = path
+	if e.relativeTo != nil {
+		doc.Path = relativePath(path, e.relativeTo)
+		if doc.Archive != "" {
+			doc.Archive = relativePath(doc.Archive, e.relativeTo)
+		}
+	}
+
+	if err := e.pages.check(doc.PageCount); err != nil {
+		return document.GhlighDoc{}, err
+	}
+	doc.AnnotsBuffer = e.subtypes.filter(e.colors.filter(e.pages.filter(doc.AnnotsBuffer)))
+	// pdf object order changes between tools, reading order doesn't
+	doc.AnnotsBuffer.SortReadingOrder()
+	for page := range doc.PageSizes {
+		_, annotated := doc.AnnotsBuffer[page]
+		if !e.pages.contains(page) || ((e.colors != nil || e.subtypes != nil) && !annotated) {
+			delete(doc.PageSizes, page)
+		}
+	}
+	// a new slice, the cached one is shared
+	var pageErrors []document.PageError
+	for _, pe := range doc.PageErrors {
+		if e.pages.contains(pe.Page) {
+			pageErrors = append(pageErrors, pe)
+		}
+	}
+	doc.PageErrors = pageErrors
+	return doc, nil
+}
+
+// read exports path without looking at the cache
+func (e *exporter) read(path string) (document.GhlighDoc, error) {
+	doc, err := openPDF(path, e.password)
+	if err != nil {
+		return document.GhlighDoc{}, err
+	}
+	defer doc.Close()
+
+	if e.ocr != nil {
+		doc.SetOCR(e.ocr)
+	}
+	if e.withText {
+		doc.AnnotsBuffer = doc.GetAnnotsText()
+	} else {
+		doc.AnnotsBuffer = doc.GetAnnotsBuffer()
+	}
+	doc.HashBuffer, err = doc.HashDocMode(e.hashMode)
+	if err != nil {
+		return document.GhlighDoc{}, err
+	}
+	doc.HashMode = e.hashMode
+	doc.Version = document.FormatVersion
+	doc.LoadInfo()
+	return doc.Exported(), nil
+}
+
+// stream exports pdfs using up to e.concurrency workers, every document is
+// sent on the returned channel as soon as it is ready. Once e.ctx is done
+// the remaining files are skipped and the channel is closed as soon as the
+// running exports finish.
+func (e *exporter) stream(pdfs []string) <-chan document.GhlighDoc {
+	paths := make(chan string)
+	results := make(chan document.GhlighDoc)
+
+	var done <-chan struct{}
+	if e.ctx != nil {
+		done = e.ctx.Done()
+	}
+
+	var processed atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(e.concurrency)
+	for i := 0; i < e.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				doc, err := e.exportTimeout(path)
+				if e.onProgress != nil {
+					e.onProgress(int(processed.Add(1)), len(pdfs))
+				}
+				if err != nil {
+					if e.onError != nil {
+						e.onError(path, err)
+					}
+					continue
+				}
+				if e.hashes != nil && !e.hashes[doc.HashBuffer] {
+					continue
+				}
+				select {
+				case results <- doc:
+				case <-done:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(paths)
+		for _, path := range pdfs {
+			select {
+			case paths <- path:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// exportAll is like stream but waits for every document, the result is
+// sorted by path so that the output doesn't depend on scheduling
+func (e *exporter) exportAll(pdfs []string) []document.GhlighDoc {
+	var exportedDocs []document.GhlighDoc
+	for doc := range e.stream(pdfs) {
+		exportedDocs = append(exportedDocs, doc)
+	}
+
+	sort.Slice(exportedDocs, func(i, j int) bool {
+		return exportedDocs[i].Path < exportedDocs[j].Path
+	})
+
+	return exportedDocs
+}
+
+// formats accepted by export --format
+var exportFormats = []string{"json", "markdown", "obsidian", "csv", "tsv", "readwise"}
+
+func validExportFormat(format string) bool {
+	for _, f := range exportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDelimiter parses the --delimiter of csv exports, a single character
+// or "tab"
+func parseDelimiter(s string) (rune, error) {
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' || runes[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q, use a single character like ; or tab", s)
+	}
+	return runes[0], nil
+}
+
+// writeExport renders docs in the given format, json is the format import
+// understands, the others are for humans. delimiter separates the fields
+// of csv, 0 for a comma.
+func writeExport(w io.Writer, format string, docs []document.GhlighDoc, indent bool, delimiter rune) error {
+	switch format {
+	case "markdown":
+		for i := range docs {
+			if i > 0 {
+				fmt.Fprintf(w, "\n")
+			}
+			if err := docs[i].ExportMarkdown(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "obsidian":
+		for i := range docs {
+			if i > 0 {
+				fmt.Fprintf(w, "\n")
+			}
+			if err := docs[i].ExportObsidian(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		if delimiter == 0 {
+			delimiter = ','
+		}
+		return document.ExportCSVDelimiter(w, docs, delimiter)
+	case "tsv":
+		return document.ExportTSV(w, docs)
+	case "readwise":
+		return document.ExportReadwise(w, docs, indent)
+	default:
+		var jsonBytes []byte
+		var err error
+		if indent {
+			jsonBytes, err = json.MarshalIndent(docs, "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(docs)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", jsonBytes)
+		return err
+	}
+}
+
 func writeJSONToFile(jsonBytes []byte, path string) error {
 	file, err := os.Create(path)
 	if err != nil {
@@ -35,16 +607,109 @@ var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "export pdf highlights into json",
 	Long: `
-	ghligh export foo.pdf bar.pdf ... [--to fnord.json] [-1] [-i]
+	ghligh export foo.pdf bar.pdf ... [--root dir] [--to fnord.json] [-1] [-i] [--text]
+		[--format json|markdown|obsidian|csv|tsv|readwise] [--delimiter ;] [--hash-mode text|bytes] [--pages 5-20,33]
+		[--password secret] [--no-cache] [--relative] [--color red] [--subtype Underline]
+		[--ocr [--ocr-lang eng]]
+		[--per-file-timeout 0] [--out-dir dir [--name-template '{{.Title}}']]
 
 	will create one or more json file (specified with --to) or dump it
-	to stdout (-1)
+	to stdout (-1), if no --to is given the json is dumped to stdout
+
+	--root will also export every pdf found recursively inside dir, like
+	ghligh serve does, it can be repeated to export several directories
+	at once (a file under more than one of them is exported once),
+	symlinked directories are only followed with --follow-symlinks, only
+	files matching an --include pattern (if any) are considered and paths
+	matching --exclude patterns are skipped, with --zip the pdfs
+	inside .zip archives are exported too, named
+	archive.zip!/path/in/archive.pdf and with the archive under "archive"
+
+	--color (repeatable) only exports the annotations of that color, as
+	#rrggbb, #rgb or one of yellow, red, green, blue, cyan, orange, purple,
+	pink, gray and black. Colors close enough to it match too, since pdf
+	viewers don't agree on the exact shade of their highlighters
+
+	--subtype (repeatable) only exports the annotations of that subtype,
+	one of Highlight, Underline, Squiggly, StrikeOut and FreeText (case
+	doesn't matter), it combines with --color and --pages
+
+	--relative writes the file paths relative to the --root they were
+	found under (or to the current directory), so that an export can be
+	shared with other machines
+
+	--text will also export the text covered by every highlight
+
+	--ocr recognizes the text of highlights on scanned pages (pages without
+	a text layer) with tesseract, which must be installed, it implies
+	--text. --ocr-lang is passed to tesseract -l, like eng (the default)
+	or eng+ita. Recognized text is cached by language so repeated exports
+	don't run tesseract again
+
+	every document has its title (from the pdf metadata, or the file name),
+	author and page count, and the width and height (in pdf points) of
+	every annotated page under "pages", annotation coordinates are relative
+	to them
+
+	pages of damaged pdfs that can't be read are skipped, reported on
+	stderr and listed under "page_errors", the annotations of the other
+	pages are still exported
+
+	--format markdown writes, for every document, a heading per page and the
+	highlighted text of each annotation as a list, with notes quoted below
+
+	--format obsidian writes an Obsidian note per document: yaml
+	frontmatter (title, author, source file, pages, hash and tags), a
+	heading per page linking to that page of the pdf and a callout per
+	annotation, whose type and #color/ tag follow the highlighter color
+	(yellow is a quote, red danger, green success...), with the note
+	inside it. Use it with --out-dir to get one file per document
+
+	--format csv writes one row per annotation with file, page, subtype,
+	color, author, text and note columns, separated by commas or by the
+	--delimiter character (like ; for spreadsheets of locales writing
+	decimals with a comma, or tab). --format tsv writes the same rows tab
+	separated without any quoting, tabs and line breaks in the text
+	become spaces
+
+	--format readwise writes the body of a readwise highlights import
+	(POST https://readwise.io/api/v2/highlights/), titled after the pdf
+	metadata title or the file name, with the page as location
+
+	--out-dir writes every document to its own file in dir, in --format,
+	instead of all of them to stdout. Files are named by --name-template,
+	a go template of {{.Title}}, {{.Author}}, {{.File}} (the pdf name
+	without .pdf), {{.Hash}} and {{.Pages}}, like '{{.Title}} ({{.Author}})'.
+	Characters that can't be in a file name are replaced by _ and
+	documents ending up with the same name get " (2)", " (3)"... appended
+
+	--pages only exports the highlights of the given pages (numbered from 1),
+	ranges are cut to the pages of each document, documents having none of
+	the requested pages are reported and skipped
+
+	--password opens encrypted pdfs, encrypted pdfs that can't be opened
+	with it are reported on stderr
+
+	--per-file-timeout skips files taking longer than that (30s, 2m) to
+	export, reporting them on stderr as timed out, so that a broken pdf
+	doesn't stall the others. 0, the default, waits as long as it takes
+
+	exports are cached in the user cache directory (~/.cache/ghligh on
+	linux) and files whose size and modification time didn't change are
+	not read again, --no-cache reads every file
+
+	--hash-mode chooses how documents are identified on import: text (the
+	default) hashes the text of some pages so copies with different bytes
+	still match, bytes hashes the whole file so only identical files match
+
+	when stderr is a terminal the number of files processed is shown
+	while exporting
 
 	-i will indent the json output
 `,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		if len(args) == 0 && len(exportRoots) == 0 {
 			cmd.Help()
 			return
 		}
@@ -61,43 +726,237 @@ var exportCmd = &cobra.Command{
 			return
 		}
 
-		if !stdout && len(outputFiles) == 0 {
-			fmt.Fprintf(os.Stderr, "nowhere to put output I am not doing anything\n")
+		outDir, err := cmd.Flags().GetString("out-dir")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		nameTemplate, err := cmd.Flags().GetString("name-template")
+		if err != nil {
+			cmd.Help()
 			return
 		}
 
-		var exportedDocs []document.GhlighDoc
-		for _, file := range args {
-			doc, err := document.Open(file)
+		tmpl, err := parseNameTemplate(nameTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if len(outputFiles) == 0 && outDir == "" {
+			stdout = true
+		}
+
+		files := args
+		if len(exportRoots) > 0 {
+			pdfs, err := scanRoots(context.Background(), exportRoots, cliScanOptions())
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "error loading %s: %v", file, err)
-				continue
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
 			}
+			files = append(files, pdfs...)
+		}
 
-			doc.AnnotsBuffer = doc.GetAnnotsBuffer()
-			doc.HashBuffer = doc.HashDoc()
-			exportedDocs = append(exportedDocs, *doc)
+		withText, err := cmd.Flags().GetBool("text")
+		if err != nil {
+			cmd.Help()
+			return
 		}
 
-		var jsonBytes []byte
-		if indent {
-			jsonBytes, err = json.MarshalIndent(exportedDocs, "", "	")
-		} else {
-			jsonBytes, err = json.Marshal(exportedDocs)
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if !validExportFormat(format) {
+			fmt.Fprintf(os.Stderr, "unknown format %s, valid formats are %v\n", format, exportFormats)
+			os.Exit(1)
+		}
+		// highlighted text is the whole point of the non json formats
+		if format != "json" {
+			withText = true
+		}
+
+		delimiterFlag, err := cmd.Flags().GetString("delimiter")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		var delimiter rune
+		if delimiterFlag != "" {
+			if format != "csv" {
+				fmt.Fprintf(os.Stderr, "--delimiter only applies to --format csv\n")
+				os.Exit(1)
+			}
+			delimiter, err = parseDelimiter(delimiterFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		hashModeFlag, err := cmd.Flags().GetString("hash-mode")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		hashMode, err := document.ParseHashMode(hashModeFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		pagesFlag, err := cmd.Flags().GetString("pages")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		pages, err := parsePageRanges(pagesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		fileTimeout, err := cmd.Flags().GetDuration("per-file-timeout")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		noCache, err := cmd.Flags().GetBool("no-cache")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		var cache *exportCache
+		if !noCache {
+			cache, err = openExportCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "not using the cache: %v\n", err)
+			}
+		}
+
+		colorFlags, err := cmd.Flags().GetStringArray("color")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		colors, err := parseColorFilter(colorFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		subtypeFlags, err := cmd.Flags().GetStringArray("subtype")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		subtypes, err := parseSubtypeFilter(subtypeFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		relative, err := cmd.Flags().GetBool("relative")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		var relativeTo []string
+		if relative {
+			relativeTo = append(append(relativeTo, exportRoots...), ".")
+		}
+
+		useOCR, err := cmd.Flags().GetBool("ocr")
+		if err != nil {
+			cmd.Help()
+			return
 		}
+
+		ocrLang, err := cmd.Flags().GetString("ocr-lang")
 		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if ocrLang == "" {
+			// what tesseract uses without -l, "" would look like no ocr
+			// in the cache keys
+			ocrLang = "eng"
+		}
+
+		var ocr document.OCRFunc
+		if useOCR {
+			tesseract, err := newTesseractOCR(ocrLang)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			ocr = tesseract.recognize
+			withText = true
+		}
+
+		e := &exporter{
+			concurrency: runtime.NumCPU(),
+			cache:       cache,
+			withText:    withText,
+			ocr:         ocr,
+			ocrLang:     ocrLang,
+			hashMode:    hashMode,
+			pages:       pages,
+			colors:      colors,
+			subtypes:    subtypes,
+			password:    password,
+			fileTimeout: fileTimeout,
+			relativeTo:  relativeTo,
+			onProgress:  stderrProgress("exporting"),
+			onError: func(path string, err error) {
+				fmt.Fprintf(os.Stderr, "error loading %s: %v\n", path, err)
+			},
+		}
+		exportedDocs := e.exportAll(files)
+		for _, doc := range exportedDocs {
+			for _, pe := range doc.PageErrors {
+				fmt.Fprintf(os.Stderr, "%s: skipped page %d: %s\n", doc.Path, pe.Page+1, pe.Error)
+			}
+		}
+
+		if outDir != "" {
+			if _, err := writeEachDocument(outDir, tmpl, format, exportedDocs, indent, delimiter); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		var output bytes.Buffer
+		if err := writeExport(&output, format, exportedDocs, indent, delimiter); err != nil {
 			panic(err)
 		}
 
 		for _, file := range outputFiles {
-			err := writeJSONToFile(jsonBytes, file)
+			err := writeJSONToFile(output.Bytes(), file)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 			}
 		}
 
 		if stdout {
-			fmt.Printf("%s\n", string(jsonBytes))
+			os.Stdout.Write(output.Bytes())
 		}
 
 	},
@@ -118,6 +977,35 @@ func init() {
 	// TODO flag toFiles
 	exportCmd.Flags().BoolP("indent", "i", false, "indent the json data")
 	exportCmd.Flags().BoolP("stdout", "1", false, "dump to stdout")
+	exportCmd.Flags().Bool("text", false, "also export the highlighted text")
+	exportCmd.Flags().Bool("ocr", false, "recognize the text of highlights on scanned pages with tesseract")
+	exportCmd.Flags().String("ocr-lang", "eng", "language of the scanned pages, as tesseract -l")
+	exportCmd.Flags().String("format", "json", "output format (json, markdown, obsidian, csv, tsv, readwise)")
+	exportCmd.Flags().String("delimiter", "", "field separator of --format csv, a character or tab")
+	exportCmd.Flags().Bool("no-cache", false, "read every pdf instead of using cached exports")
+	exportCmd.Flags().StringArray("color", []string{}, "only export annotations of this color, #rrggbb or a name (repeatable)")
+	exportCmd.Flags().StringArray("subtype", []string{}, "only export annotations of this subtype, like Highlight (repeatable)")
+	exportCmd.Flags().Bool("relative", false, "write file paths relative to --root")
+	exportCmd.Flags().String("password", "", "password of encrypted pdfs")
+	exportCmd.Flags().Duration("per-file-timeout", 0, "give up on files taking longer than this (0 waits)")
+	exportCmd.Flags().String("pages", "", "only export these pages, e.g. 5-20,33")
+	exportCmd.Flags().String("hash-mode", string(document.HashText), "how documents are identified (text, bytes)")
 
 	exportCmd.Flags().StringArrayVarP(&outputFiles, "to", "t", []string{}, "files to save exported annots")
+	exportCmd.Flags().String("out-dir", "", "write every document to its own file in this directory")
+	exportCmd.Flags().String("name-template", defaultNameTemplate, "go template naming the files of --out-dir")
+	exportCmd.Flags().BoolVar(&scanArchives, "zip", false, "also export the pdfs inside .zip archives of --root")
+	exportCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories of --root")
+	exportCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "only consider files of --root matching this glob pattern (repeatable)")
+	exportCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "skip paths of --root matching this glob pattern (repeatable)")
+	exportCmd.Flags().StringArrayVarP(&exportRoots, "root", "r", []string{}, "also export every pdf found recursively in this directory (repeatable)")
+
+	exportCmd.ValidArgsFunction = completePDFs
+	registerCompletion(exportCmd, "format", exportFormats...)
+	registerCompletion(exportCmd, "hash-mode", hashModeNames()...)
+	registerCompletion(exportCmd, "color", colorNames()...)
+	registerCompletion(exportCmd, "subtype", document.SubtypeNames()...)
+	registerFileCompletion(exportCmd, "to", "json")
+	registerFileCompletion(exportCmd, "root", "")
+	registerFileCompletion(exportCmd, "out-dir", "")
 }
@@ -0,0 +1,156 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// flattenSuffix replaces .pdf in the name of flattened copies
+const flattenSuffix = ".flat.pdf"
+
+// flattenOut returns where the flattened copy of path goes: out if set,
+// else <name>.flat.pdf next to it
+func flattenOut(path string, out string) string {
+	if out != "" {
+		return out
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + flattenSuffix
+}
+
+// flattenFile bakes the annotations of the pdf at path into the pages of
+// the pdf at out, which can be path itself
+func flattenFile(path string, out string, password string, keepAnnots bool, backup bool) error {
+	if _, _, ok := splitArchivePath(path); ok {
+		return fmt.Errorf("%s: %v", path, document.ErrReadOnly)
+	}
+
+	doc, err := document.OpenWithPassword(path, password)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	defer doc.Close()
+
+	if backup {
+		if _, err := backupFile(path); err != nil {
+			return fmt.Errorf("%s: could not back up: %v", path, err)
+		}
+	}
+
+	if err := doc.Flatten(out, keepAnnots); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// flattenCmd represents the flatten command
+var flattenCmd = &cobra.Command{
+	Use:   "flatten",
+	Short: "draw highlights into the page content",
+	Long: `
+	ghligh flatten foo.pdf bar.pdf ... [--keep-annots] [--in-place [--backup]] [--password secret]
+	ghligh flatten foo.pdf --out flat.pdf [--keep-annots] [--password secret]
+
+	writes foo.flat.pdf, bar.flat.pdf etc... next to foo.pdf bar.pdf
+	drawing every page with its highlights and other annotations as part
+	of the page content, so that they show on printers and e-readers that
+	don't render annotations. --out names the copy of a single pdf and
+	--in-place replaces the pdfs with their flattened copy instead
+
+	the annotations themselves are not in the copy, --keep-annots doesn't
+	draw the highlights and other markup annotations but keeps them as
+	annotations, only the other ones (stamps, drawings...) are flattened
+
+	the pages are redrawn: links, outlines, form fields and metadata of the
+	original are not in the copy and its text may not hash like the
+	original, so export and import won't match it with the original
+	document
+
+	--backup copies every pdf to <name>.ghligh-<time>.bak.pdf before
+	replacing it with --in-place (see ghligh import),
+	--password opens encrypted pdfs, their flattened copy is not encrypted
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			return
+		}
+
+		keepAnnots, err := cmd.Flags().GetBool("keep-annots")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		backup, err := cmd.Flags().GetBool("backup")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		out, err := cmd.Flags().GetString("out")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		inPlace, err := cmd.Flags().GetBool("in-place")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		switch {
+		case out != "" && inPlace:
+			fmt.Fprintf(os.Stderr, "--out and --in-place can't be used together\n")
+			os.Exit(1)
+		case out != "" && len(args) > 1:
+			fmt.Fprintf(os.Stderr, "--out needs a single pdf\n")
+			os.Exit(1)
+		case backup && !inPlace:
+			fmt.Fprintf(os.Stderr, "--backup needs --in-place, the original is left untouched otherwise\n")
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, arg := range args {
+			dest := arg
+			if !inPlace {
+				dest = flattenOut(arg, out)
+			}
+			if err := flattenFile(arg, dest, password, keepAnnots, backup); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(flattenCmd)
+
+	flattenCmd.Flags().Bool("keep-annots", false, "keep the markup annotations as annotations instead of drawing them")
+	flattenCmd.Flags().String("out", "", "write the flattened copy of a single pdf there instead of <name>.flat.pdf")
+	flattenCmd.Flags().Bool("in-place", false, "replace the pdfs with their flattened copy")
+	flattenCmd.Flags().Bool("backup", false, "with --in-place, copy every pdf to <name>.ghligh-<time>.bak.pdf before replacing it")
+	flattenCmd.Flags().String("password", "", "password of encrypted pdfs")
+
+	flattenCmd.ValidArgsFunction = completePDFs
+	registerFileCompletion(flattenCmd, "out", "pdf")
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// highlightFile highlights every query in the pdf at path, on page (an
+// index) or on every page if page is negative, it returns how many
+// highlights were added
+func highlightFile(path string, queries []string, page int, password string, save bool, backup bool) (int, error) {
+	if _, _, ok := splitArchivePath(path); ok && save {
+		return 0, document.ErrReadOnly
+	}
+
+	doc, err := openPDF(path, password)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+
+	first, last := page, page
+	if page < 0 {
+		first, last = 0, doc.GetNPages()-1
+	}
+
+	added := 0
+	for i := first; i <= last; i++ {
+		for _, query := range queries {
+			n, err := doc.HighlightText(i, query)
+			added += n
+			if err != nil {
+				return added, err
+			}
+		}
+	}
+
+	if added == 0 || !save {
+		return added, nil
+	}
+
+	if backup {
+		if _, err := backupFile(path); err != nil {
+			return added, fmt.Errorf("could not back up: %v", err)
+		}
+	}
+	if _, err := doc.Save(); err != nil {
+		return added, err
+	}
+	return added, nil
+}
+
+// highlightCmd represents the highlight command
+var highlightCmd = &cobra.Command{
+	Use:   "highlight",
+	Short: "highlight the occurrences of some text",
+	Long: `
+	ghligh highlight foo.pdf bar.pdf ... --text "some words" [--text "other words"] [--page n]
+		[--save=false] [--backup] [--password secret]
+
+	searches the text of foo.pdf bar.pdf etc... for every --text (ignoring
+	case, an occurrence can span lines) and highlights what it finds,
+	occurrences already highlighted are left alone
+
+	--page only searches that page (numbered from 1), by default every page
+	is searched
+
+	--save=false only tells how many highlights would be added, --backup
+	copies every pdf to <name>.ghligh-<time>.bak.pdf before saving it (see
+	ghligh import) and --password opens encrypted pdfs
+
+	for every file the number of highlights added is printed
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		queries, err := cmd.Flags().GetStringArray("text")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if len(args) == 0 || len(queries) == 0 {
+			cmd.Help()
+			return
+		}
+
+		page, err := cmd.Flags().GetInt("page")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if page < 0 {
+			fmt.Fprintf(os.Stderr, "invalid page %d, pages are numbered from 1\n", page)
+			os.Exit(1)
+		}
+
+		save, err := cmd.Flags().GetBool("save")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		backup, err := cmd.Flags().GetBool("backup")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		failed := false
+		for _, arg := range args {
+			added, err := highlightFile(arg, queries, page-1, password, save, backup)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", arg, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: %d highlights added\n", arg, added)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(highlightCmd)
+
+	highlightCmd.Flags().StringArrayP("text", "t", []string{}, "text to highlight (repeatable)")
+	highlightCmd.Flags().IntP("page", "p", 0, "only search this page, from 1 (0 searches every page)")
+	highlightCmd.Flags().Bool("save", true, "save the highlighted files")
+	highlightCmd.Flags().Bool("backup", false, "copy every pdf to <name>.ghligh-<time>.bak.pdf before saving it")
+	highlightCmd.Flags().String("password", "", "password of encrypted pdfs")
+
+	highlightCmd.ValidArgsFunction = completePDFs
+}
@@ -4,11 +4,23 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/prepuzio/ghligh/document"
 	"github.com/spf13/cobra"
@@ -18,12 +30,83 @@ import (
 
 var inputFiles []string
 
+// importInFiles are the files of import --in, the same as --from
+var importInFiles []string
+
+var importRoots []string
+
+type importFileResult struct {
+	File     string `json:"file"`
+	Imported int    `json:"imported"`
+	Removed  int    `json:"removed,omitempty"`
+	Saved    bool   `json:"saved"`
+	Error    string `json:"error,omitempty"`
+	// MatchedBy is "hash", "fuzzy" (see importer.fuzzy) or "forced" (see
+	// importer.targeted), MatchedHash is the hash of the imported document
+	MatchedBy   string `json:"matchedBy,omitempty"`
+	MatchedHash string `json:"matchedHash,omitempty"`
+	// Skipped files were left untouched, Reason tells why
+	Skipped bool   `json:"skipped,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// Warning is set for files matched without their hash (see MatchedBy):
+	// their content differs from the exported one
+	Warning string `json:"warning,omitempty"`
+	// Backup is the copy of the file made before saving, see importer.backup
+	Backup string `json:"backup,omitempty"`
+	// Written is where the file was saved with --out-dir, see
+	// importer.outDir
+	Written string `json:"written,omitempty"`
+	// Clamped annotations were cut to the bounds of their page, Dropped
+	// ones were entirely outside of it
+	Clamped int `json:"clamped,omitempty"`
+	Dropped int `json:"dropped,omitempty"`
+	// TimedOut is set when Error is a *fileTimeoutError: the file took
+	// longer than importer.fileTimeout and was left untouched
+	TimedOut bool `json:"timedOut,omitempty"`
+	// Retries is how many times saving was tried again, see
+	// importer.saveRetries
+	Retries int `json:"retries,omitempty"`
+}
+
+type importSummary struct {
+	Files         []importFileResult `json:"files"`
+	TotalImported int                `json:"totalImported"`
+	TotalRemoved  int                `json:"totalRemoved,omitempty"`
+	// Errors is how many files failed, they have an error in Files
+	Errors int `json:"errors"`
+	// Duplicates lists, by imported document hash, the files that matched
+	// the same imported document, see importer.duplicates
+	Duplicates map[string][]string `json:"duplicates,omitempty"`
+}
+
 type importedAnnots struct {
 	// FIXME just internatl map[string]struct where
 	//	struct contains map[string]bool and document.AnnotsMap
 	internal     map[string]document.AnnotsMap
 	annotsHashes map[string]map[string]bool
-	mutex        sync.Mutex
+	// hash modes used by the imported documents
+	modes map[document.HashMode]bool
+	// title of the imported documents by hash, for fuzzy matching
+	titles map[string]string
+	// page count and page sizes of the imported documents by hash, to
+	// tell if documents matched without their hash changed too much
+	layouts map[string]importedLayout
+	mutex   sync.Mutex
+}
+
+type importedLayout struct {
+	pageCount int
+	sizes     map[int]document.PageSize
+}
+
+func newImportedAnnots() *importedAnnots {
+	return &importedAnnots{
+		internal:     make(map[string]document.AnnotsMap),
+		annotsHashes: make(map[string]map[string]bool),
+		modes:        make(map[document.HashMode]bool),
+		titles:       make(map[string]string),
+		layouts:      make(map[string]importedLayout),
+	}
 }
 
 func (ia *importedAnnots) get(hash string) document.AnnotsMap {
@@ -76,46 +159,887 @@ func hashAnnotsMap(am document.AnnotsMap) (string, error) {
 	return fmt.Sprintf("%x", h), nil
 }
 
-func loadImportedAnnots(ia *importedAnnots, reader io.Reader) {
+// loadImportedAnnots reads an export file from reader into ia
+func loadImportedAnnots(ia *importedAnnots, reader io.Reader) error {
 	data, err := io.ReadAll(reader)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "could not read data: %v\n", err)
-		return
+		return fmt.Errorf("could not read data: %v", err)
+	}
+
+	importedDocs, err := parseExport(data)
+	if err != nil {
+		return err
+	}
+
+	ia.load(importedDocs)
+	return nil
+}
+
+// parseExport decodes the json written by ghligh export, the errors for
+// the usual mistakes (nothing at all, a single document instead of the
+// array) tell what was expected
+func parseExport(data []byte) ([]document.GhlighDoc, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty input, expected the json array written by ghligh export")
+	}
+	if trimmed[0] == '{' {
+		return nil, fmt.Errorf("expected a json array of documents but got an object, a single exported document must be wrapped in [ ]")
 	}
 
 	var importedDocs []document.GhlighDoc
+	if err := json.Unmarshal(data, &importedDocs); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+	if err := document.CheckVersion(importedDocs); err != nil {
+		return nil, err
+	}
+	return importedDocs, nil
+}
 
-	err = json.Unmarshal(data, &importedDocs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+// loadImportFiles reads the export files in paths into ia concurrently,
+// it prints why the others couldn't be read and returns false if any
+// failed
+func loadImportFiles(ia *importedAnnots, paths []string) bool {
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	wg.Add(len(paths))
+	for _, file := range paths {
+		go func(path string) {
+			defer wg.Done()
+
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not open file %s: %v\n", path, err)
+				failed.Store(true)
+				return
+			}
+			defer f.Close()
+
+			if err := loadImportedAnnots(ia, f); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				failed.Store(true)
+			}
+		}(file)
 	}
 
+	wg.Wait()
+	return !failed.Load()
+}
+
+// load groups the annotations of importedDocs by document hash
+func (ia *importedAnnots) load(importedDocs []document.GhlighDoc) {
 	for _, importedDoc := range importedDocs {
 		hash := importedDoc.HashBuffer
+		if hash == "" {
+			continue
+		}
+
+		mode, err := document.ParseHashMode(string(importedDoc.HashMode))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", importedDoc.Path, err)
+			continue
+		}
+
+		ia.mutex.Lock()
+		ia.modes[mode] = true
+		// exports made before titles were exported only have the file name
+		title := importedDoc.Title
+		if title == "" {
+			title = document.FileTitle(importedDoc.Path)
+		}
+		ia.titles[hash] = title
+		layout, ok := ia.layouts[hash]
+		if !ok {
+			layout = importedLayout{pageCount: importedDoc.PageCount, sizes: make(map[int]document.PageSize)}
+		}
+		for page, size := range importedDoc.PageSizes {
+			layout.sizes[page] = size
+		}
+		ia.layouts[hash] = layout
+		ia.mutex.Unlock()
+
 		ia.init(hash)
 		ia.insert(hash, importedDoc.AnnotsBuffer)
 	}
 }
 
+// lookup returns the annotations imported for doc, trying every hash mode
+// used by the imported documents
+func (ia *importedAnnots) lookup(doc *document.GhlighDoc) (document.AnnotsMap, error) {
+	_, am, err := ia.lookupHash(doc)
+	return am, err
+}
+
+// lookupHash is like lookup but also returns the hash that matched
+func (ia *importedAnnots) lookupHash(doc *document.GhlighDoc) (string, document.AnnotsMap, error) {
+	for _, mode := range document.HashModes {
+		if !ia.modes[mode] {
+			continue
+		}
+
+		hash, err := doc.HashDocMode(mode)
+		if err != nil {
+			return "", nil, err
+		}
+		if am := ia.get(hash); len(am) > 0 {
+			return hash, am, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// only returns the annotations of the imported document when the import
+// has a single one
+func (ia *importedAnnots) only() (string, document.AnnotsMap, error) {
+	if len(ia.internal) != 1 {
+		return "", nil, fmt.Errorf("no imported document has the same hash and the import has %d documents, can't tell which one to use", len(ia.internal))
+	}
+	for hash, am := range ia.internal {
+		return hash, am, nil
+	}
+	return "", nil, nil
+}
+
+// documents whose titles are at least this similar are considered the
+// same paper by fuzzy matching
+const fuzzyThreshold = 0.8
+
+// titleWords lowercases title and splits it into words so that titles
+// differing only in case, punctuation or separators compare equal
+func titleWords(title string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		words[w] = true
+	}
+	return words
+}
+
+// titleSimilarity is the jaccard index of the words of a and b
+func titleSimilarity(a, b string) float64 {
+	wa, wb := titleWords(a), titleWords(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(wa)+len(wb)-common)
+}
+
+// fuzzyLookup returns the annotations of the imported document whose title
+// is the most similar to one of titles, if above fuzzyThreshold
+func (ia *importedAnnots) fuzzyLookup(titles ...string) (string, document.AnnotsMap) {
+	bestHash, bestScore := "", 0.0
+	for hash, importedTitle := range ia.titles {
+		for _, title := range titles {
+			score := titleSimilarity(title, importedTitle)
+			if score > bestScore || (score == bestScore && hash < bestHash) {
+				bestHash, bestScore = hash, score
+			}
+		}
+	}
+
+	if bestScore < fuzzyThreshold {
+		return "", nil
+	}
+	return bestHash, ia.get(bestHash)
+}
+
+// importer applies imported annotations to pdf files, it is shared by the
+// import command and the /import endpoint
+type importer struct {
+	// save the documents that received new annotations
+	save bool
+	// when no imported document has the same hash, import the one with the
+	// most similar title (metadata title or file name)
+	fuzzy bool
+	// add annotations even if already present
+	force bool
+	// import into files matched without their hash even if their layout
+	// changed since the export, see document.GhlighDoc.LayoutChange
+	ignoreLayout bool
+	// fail instead of fitting annotations outside of their page
+	strict bool
+	// remove the imported annotations from the documents instead of
+	// adding them, see ghligh clean
+	remove bool
+	// copy every file to <name>.ghligh-<time>.bak.pdf before saving it
+	backup bool
+	// used to open encrypted pdfs
+	password string
+	// how many files are processed at the same time
+	concurrency int
+	// a single file was named explicitly, with ignoreHash it gets the
+	// annotations of the import even if no hash matches
+	targeted bool
+	// see targeted
+	ignoreHash bool
+	// what to do with files matching the same imported document
+	duplicates duplicatePolicy
+	// how many times a save failing with a retryable error is tried again
+	saveRetries int
+	// give up on files taking longer than this, 0 never does
+	fileTimeout time.Duration
+	// hashes of files already seen by the server, nil to always hash
+	docs *docCache
+	// only import the documents with these hashes, nil imports every one
+	only map[string]bool
+	// when set files are saved under this directory instead of in place,
+	// at their path relative to the first of outRoots containing them
+	outDir   string
+	outRoots []string
+
+	// set by importAll following the duplicate policy: files skipped and
+	// files failed, with the reason
+	excluded map[string]string
+	failed   map[string]string
+	// set by importAll with outDir, see outPaths
+	outs map[string]string
+}
+
+// duplicatePolicy tells what to do when more than one file matches the
+// same imported document (identical copies of a pdf for instance)
+type duplicatePolicy string
+
+const (
+	// import into every matching file
+	duplicateAll duplicatePolicy = "all"
+	// import into the first matching file by path, skip the others
+	duplicateFirst duplicatePolicy = "first"
+	// import into none of the matching files, reporting an error
+	duplicateError duplicatePolicy = "error"
+)
+
+var duplicatePolicies = []string{string(duplicateAll), string(duplicateFirst), string(duplicateError)}
+
+func parseDuplicatePolicy(s string) (duplicatePolicy, error) {
+	switch duplicatePolicy(s) {
+	case "", duplicateAll:
+		return duplicateAll, nil
+	case duplicateFirst, duplicateError:
+		return duplicatePolicy(s), nil
+	}
+	return "", fmt.Errorf("invalid duplicate policy %q, use one of %v", s, duplicatePolicies)
+}
+
+// backups are named like the original with .ghligh-<time>.bak.pdf instead
+// of .pdf, every save gets its own so that none of them goes stale
+const backupSuffix = ".bak.pdf"
+
+// backupTimeFormat is the <time> of backup names
+const backupTimeFormat = "20060102-150405"
+
+// backupName matches the names of the backups made by backupFile, other
+// files ending in .bak.pdf belong to the user
+var backupName = regexp.MustCompile(`\.ghligh-\d{8}-\d{6}(-\d+)?\.bak\.pdf$`)
+
+// isBackup reports whether path is a backup made by backupFile
+func isBackup(path string) bool {
+	return backupName.MatchString(path)
+}
+
+// backupFile copies the file at path to <name>.ghligh-<time>.bak.pdf and
+// returns the path of the copy. Backups made in the same second get -2,
+// -3... appended to the time, an existing file is never overwritten.
+func backupFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path)) + ".ghligh-" + time.Now().Format(backupTimeFormat)
+	backup := base + backupSuffix
+	dst, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	for n := 2; errors.Is(err, os.ErrExist); n++ {
+		backup = fmt.Sprintf("%s-%d%s", base, n, backupSuffix)
+		dst, err = os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(backup)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
+
+// match returns the imported annotations for doc and the hash of the
+// imported document they belong to, matchedBy tells how it was found
+func (im *importer) match(doc *document.GhlighDoc, path string, ia *importedAnnots) (hash string, am document.AnnotsMap, matchedBy string, err error) {
+	hash, am, err = im.docs.lookupHash(ia, doc, path)
+	if err != nil || len(am) > 0 {
+		return hash, am, "hash", err
+	}
+	if im.fuzzy {
+		hash, am = ia.fuzzyLookup(doc.Info().Title, document.FileTitle(path))
+		if len(am) > 0 {
+			return hash, am, "fuzzy", nil
+		}
+	}
+	if im.targeted && im.ignoreHash {
+		hash, am, err = ia.only()
+		return hash, am, "forced", err
+	}
+	return "", nil, "", nil
+}
+
+// matchFile returns the hash of the imported document matching the file at
+// path, "" if none
+func (im *importer) matchFile(path string, ia *importedAnnots) (hash string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if _, _, ok := splitArchivePath(path); ok {
+		return "", nil
+	}
+	if im.skipsUnopened(path, ia) {
+		return "", nil
+	}
+
+	doc, err := document.OpenWithPassword(path, im.password)
+	if err != nil {
+		return "", err
+	}
+	defer doc.Close()
+
+	hash, _, _, err = im.match(doc, path, ia)
+	return hash, err
+}
+
+// selected reports whether the imported document with hash is one of
+// im.only
+func (im *importer) selected(hash string) bool {
+	return im.only == nil || im.only[hash]
+}
+
+// hashSet returns the set of hashes, nil if there are none
+func hashSet(hashes []string) map[string]bool {
+	if len(hashes) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, hash := range hashes {
+		set[hash] = true
+	}
+	return set
+}
+
+// skipsUnopened reports whether the cached hashes of path already tell that
+// no imported document matches it, only without fuzzy or forced matching
+func (im *importer) skipsUnopened(path string, ia *importedAnnots) bool {
+	if im.fuzzy || (im.targeted && im.ignoreHash) {
+		return false
+	}
+	return im.docs.knownMiss(ia, path)
+}
+
+// first wait between two attempts of saveWithRetries, doubled every time
+const saveRetryDelay = 200 * time.Millisecond
+
+// windows error codes of files held open by another process, syscall
+// doesn't name them all
+const (
+	windowsAccessDenied     = syscall.Errno(5)
+	windowsSharingViolation = syscall.Errno(32)
+	windowsLockViolation    = syscall.Errno(33)
+)
+
+// retryableSaveError reports whether a failed save may work if tried
+// again: the file busy or locked by another process or an antivirus.
+// Anything else, like missing permissions, missing files, a full disk or
+// poppler and integrity check failures, is not retried.
+func retryableSaveError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		switch errno {
+		case windowsSharingViolation, windowsLockViolation:
+			return true
+		case windowsAccessDenied:
+			// renaming over a file another process has open is denied,
+			// a directory we can't write to already failed creating the
+			// temporary file
+			var linkErr *os.LinkError
+			return errors.As(err, &linkErr)
+		}
+		return false
+	}
+
+	switch errno {
+	case syscall.EBUSY, syscall.ETXTBSY, syscall.EAGAIN:
+		return true
+	}
+	return false
+}
+
+// saveWithRetries saves doc to path trying again up to im.saveRetries
+// times, with exponential backoff, as long as the error is retryable
+func (im *importer) saveWithRetries(doc *document.GhlighDoc, path string) (saved bool, retries int, err error) {
+	delay := saveRetryDelay
+	for {
+		saved, err = doc.SaveAs(path)
+		if err == nil || retries >= im.saveRetries || !retryableSaveError(err) {
+			return saved, retries, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+		retries++
+	}
+}
+
+// outPath returns where the file at path is saved with im.outDir, the
+// place chosen by outPaths when importAll did
+func (im *importer) outPath(path string) (string, error) {
+	if out, ok := im.outs[path]; ok {
+		return out, nil
+	}
+	return im.baseOutPath(path)
+}
+
+// baseOutPath is outPath without the names made unique by outPaths, files
+// outside of every root keep only their name
+func (im *importer) baseOutPath(path string) (string, error) {
+	rel := filepath.FromSlash(relativePath(path, im.outRoots))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(path)
+	}
+	out, err := filepath.Abs(filepath.Join(im.outDir, rel))
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if out == abs {
+		return "", fmt.Errorf("--out-dir would overwrite %s", path)
+	}
+	return out, nil
+}
+
+// outPaths returns the baseOutPath of every file of pdfs, files that would
+// be saved at the same place (the same name outside of every root, the
+// same path in two roots) get " (2)", " (3)"... appended like the files of
+// export --out-dir, in the order of their paths. Files baseOutPath fails
+// for are left out, importFile reports the error.
+func (im *importer) outPaths(pdfs []string) map[string]string {
+	sorted := append([]string(nil), pdfs...)
+	sort.Strings(sorted)
+
+	outs := make(map[string]string)
+	used := make(map[string]bool)
+	for _, path := range sorted {
+		out, err := im.baseOutPath(path)
+		if err != nil {
+			continue
+		}
+		ext := filepath.Ext(out)
+		base := strings.TrimSuffix(out, ext)
+		for n := 2; used[strings.ToLower(out)]; n++ {
+			out = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		used[strings.ToLower(out)] = true
+		outs[path] = out
+	}
+	return outs
+}
+
+// importFileTimeout is importFile giving up after im.fileTimeout
+func (im *importer) importFileTimeout(path string, ia *importedAnnots) importFileResult {
+	res, err := withFileTimeout(im.fileTimeout, func(ctx context.Context) importFileResult {
+		return im.importFile(ctx, path, ia)
+	})
+	if err != nil {
+		return importFileResult{File: path, Error: err.Error(), TimedOut: true}
+	}
+	return res
+}
+
+// importFile imports into path, unless ctx is done by the time the file
+// would be saved. A panic (broken pdfs can trip the poppler bindings) is
+// reported as the error of the file.
+func (im *importer) importFile(ctx context.Context, path string, ia *importedAnnots) (res importFileResult) {
+	res.File = path
+	defer func() {
+		if r := recover(); r != nil {
+			res.Error = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+
+	if _, _, ok := splitArchivePath(path); ok {
+		res.Skipped = true
+		res.Reason = "inside a zip archive, archives are read only"
+		return res
+	}
+	if im.skipsUnopened(path, ia) {
+		res.Skipped = true
+		res.Reason = "no imported document matches"
+		return res
+	}
+
+	doc, err := document.OpenWithPassword(path, im.password)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer doc.Close()
+
+	hash, am, matchedBy, err := im.match(doc, path, ia)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if len(am) == 0 {
+		res.Skipped = true
+		res.Reason = "no imported document matches"
+		return res
+	}
+	res.MatchedBy = matchedBy
+	res.MatchedHash = hash
+
+	if !im.selected(hash) {
+		res.Skipped = true
+		res.Reason = "the matching document is not one of the selected hashes"
+		return res
+	}
+
+	if matchedBy != "hash" {
+		layout := ia.layouts[hash]
+		change := doc.LayoutChange(layout.pageCount, layout.sizes)
+		switch {
+		case change == "":
+			res.Warning = "the content differs from the exported document"
+		case !im.ignoreLayout:
+			res.Skipped = true
+			res.Reason = "changed since the export: " + change
+			return res
+		default:
+			res.Warning = "changed since the export: " + change
+		}
+	}
+
+	if reason, ok := im.excluded[path]; ok {
+		res.Skipped = true
+		res.Reason = reason
+		return res
+	}
+	if msg, ok := im.failed[path]; ok {
+		res.Error = msg
+		return res
+	}
+
+	var changed int
+	if im.remove {
+		changed, err = doc.RemoveAnnots(am)
+		res.Removed = changed
+	} else {
+		var ir document.ImportResult
+		ir, err = doc.Import(am, document.ImportOptions{Force: im.force, Strict: im.strict})
+		changed = ir.Imported
+		res.Imported = ir.Imported
+		res.Clamped = ir.Clamped
+		res.Dropped = ir.Dropped
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if changed > 0 && im.save {
+		// the result is thrown away, nothing may be written
+		if err := ctx.Err(); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+
+		dest := path
+		if im.outDir != "" {
+			dest, err = im.outPath(path)
+			if err == nil {
+				err = os.MkdirAll(filepath.Dir(dest), 0o755)
+			}
+			if err != nil {
+				res.Error = err.Error()
+				return res
+			}
+		} else if im.backup {
+			res.Backup, err = backupFile(path)
+			if err != nil {
+				res.Error = err.Error()
+				return res
+			}
+		}
+
+		saved, retries, err := im.saveWithRetries(doc, dest)
+		im.docs.forget(dest)
+		res.Saved = saved
+		res.Retries = retries
+		if saved && im.outDir != "" {
+			res.Written = dest
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}
+
+	return res
+}
+
+// forEachFile calls fn for every path using up to concurrency goroutines
+func forEachFile(paths []string, concurrency int, fn func(path string)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range ch {
+				fn(path)
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		ch <- path
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// duplicateMatches groups by imported document hash the files of pdfs
+// matching one, only groups of more than one file are returned
+func (im *importer) duplicateMatches(pdfs []string, ia *importedAnnots) map[string][]string {
+	byHash := make(map[string][]string)
+	var mu sync.Mutex
+	forEachFile(pdfs, im.concurrency, func(path string) {
+		type match struct {
+			hash string
+			err  error
+		}
+		m, err := withFileTimeout(im.fileTimeout, func(ctx context.Context) match {
+			hash, err := im.matchFile(path, ia)
+			return match{hash, err}
+		})
+		if err == nil {
+			err = m.err
+		}
+		hash := m.hash
+		if err != nil || hash == "" || !im.selected(hash) {
+			// importFile will report it
+			return
+		}
+		mu.Lock()
+		byHash[hash] = append(byHash[hash], path)
+		mu.Unlock()
+	})
+
+	return duplicateGroups(byHash)
+}
+
+func duplicateGroups(byHash map[string][]string) map[string][]string {
+	duplicates := make(map[string][]string)
+	for hash, files := range byHash {
+		if len(files) > 1 {
+			sort.Strings(files)
+			duplicates[hash] = files
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return duplicates
+}
+
+// applyDuplicatePolicy fills im.excluded and im.failed for the files of
+// duplicates
+func (im *importer) applyDuplicatePolicy(duplicates map[string][]string) {
+	im.excluded = make(map[string]string)
+	im.failed = make(map[string]string)
+	for _, files := range duplicates {
+		for i, file := range files {
+			switch {
+			case im.duplicates == duplicateFirst && i > 0:
+				im.excluded[file] = fmt.Sprintf("same document as %s, only the first file is imported", files[0])
+			case im.duplicates == duplicateError:
+				others := append(append([]string{}, files[:i]...), files[i+1:]...)
+				im.failed[file] = fmt.Sprintf("same document as %s, not importing into any of them", strings.Join(others, ", "))
+			}
+		}
+	}
+}
+
+// importAll imports the annotations in ia into every pdf with a matching
+// hash using up to im.concurrency workers, every file is reported in the
+// summary, sorted by path. Unless im.duplicates is "all" the files are
+// matched first, to find the ones matching the same imported document.
+func (im *importer) importAll(pdfs []string, ia *importedAnnots) importSummary {
+	summary := importSummary{}
+	var mu sync.Mutex
+
+	if im.duplicates != "" && im.duplicates != duplicateAll {
+		summary.Duplicates = im.duplicateMatches(pdfs, ia)
+		im.applyDuplicatePolicy(summary.Duplicates)
+	}
+	if im.outDir != "" {
+		im.outs = im.outPaths(pdfs)
+	}
+
+	forEachFile(pdfs, im.concurrency, func(path string) {
+		res := im.importFileTimeout(path, ia)
+
+		mu.Lock()
+		summary.TotalImported += res.Imported
+		summary.TotalRemoved += res.Removed
+		if res.Error != "" {
+			summary.Errors++
+		}
+		summary.Files = append(summary.Files, res)
+		mu.Unlock()
+	})
+
+	sort.Slice(summary.Files, func(i, j int) bool {
+		return summary.Files[i].File < summary.Files[j].File
+	})
+
+	if summary.Duplicates == nil {
+		byHash := make(map[string][]string)
+		for _, res := range summary.Files {
+			if res.MatchedHash != "" {
+				byHash[res.MatchedHash] = append(byHash[res.MatchedHash], res.File)
+			}
+		}
+		summary.Duplicates = duplicateGroups(byHash)
+	}
+
+	return summary
+}
+
 // importCmd represents the import command
 var importCmd = &cobra.Command{
 	Use:   "import",
 	Short: "import highlights from json file",
 	Long: `
-	ghligh import foo.pdf bar.pdf ... [--from fnord.json] [--from kadio.json] [-0] [--save=false]
+	ghligh import foo.pdf bar.pdf ... [--root dir] [--from fnord.json] [--in kadio.json] [-0] [--save=false|--dry-run] [-i] [--fuzzy] [--ignore-layout] [--force] [--backup] [--password secret]
+		[--duplicate-policy all|first|error] [--out-dir dir] [--only hash] [--summary-out summary.json]
+		[--per-file-timeout 0]
+	ghligh import --file foo.pdf --from fnord.json [--ignore-hash] [--force] [--strict]
 
 	will import into foo.pdf bar.pdf etc... the highlights from file specified
-	with the --from flag
+	with the --from flag, --in is the same and they can be mixed
+
+	--root will also import into every pdf found recursively inside dir, like
+	ghligh serve does, it can be repeated
 
 	if -0 is set ghligh will read json from stdin
 
-	--save=false will run without saving documents, it will just tells you how
-	many annotations from the json files specified will be imported
+	--save=false (or --dry-run) will run without saving documents, it will
+	just tells you how many annotations from the json files specified will be
+	imported
+
+	--backup copies every pdf to <name>.ghligh-<time>.bak.pdf (the time is
+	like 20250102-150405) before saving it, every save gets a new backup so
+	the older ones are kept. These backups are skipped when scanning --root,
+	other files ending in .bak.pdf are not
+
+	--out-dir leaves the pdfs untouched and saves the imported ones under
+	dir instead, at their path relative to the --root they were found in
+	(or to the current directory), files outside of them keep only their
+	name. Files that would be written to the same place get " (2)",
+	" (3)"... appended, the summary tells where each file was written. Use
+	--exclude if dir is inside --root
+
+	--password opens encrypted pdfs, the summary tells which files are
+	encrypted and couldn't be opened
+
+	--file imports into that file only, ignoring the other arguments and
+	--root, with --ignore-hash the annotations are imported even if the
+	hash of the file doesn't match (the json must then have a single
+	document)
+
+	annotations partly outside of their page (e.g. exported from another
+	edition of the pdf) are cut to the page bounds and the ones entirely
+	outside are dropped, the summary counts them as clamped and dropped,
+	--strict fails the import of the file instead
+
+	--only (repeatable) only imports the documents of the json with that
+	hash, see ghligh hash, files matching the other documents are reported
+	as skipped
+
+	when several files match the same imported document (identical copies
+	of a pdf) the summary lists them under "duplicates", by default all of
+	them are imported into, --duplicate-policy first only imports into the
+	first one by path and error into none of them
+
+	saving a file that fails because of what looks like a transient error
+	(the file locked by another program or an antivirus) is retried up to
+	--save-retries times waiting longer every time, errors like a missing
+	permission are reported right away
+
+	--per-file-timeout gives up on files taking longer than that (30s, 2m)
+	so that a broken pdf doesn't stall the others, they are reported with
+	"timedOut": true. The file is not saved once the timeout expired, but
+	a save already running when it does can still complete. 0, the
+	default, waits as long as it takes
+
+	annotations already in the pdf (same subtype, page and position) are not
+	imported again, --force imports them anyway
+
+	--fuzzy will import into files without a matching hash the highlights
+	of the document with the most similar title (from the pdf metadata or
+	the file name), the summary tells which files were matched this way
+
+	files matched without their hash get a warning in the summary, their
+	content is not the exported one. If they also have a different page
+	count or annotated pages of a different size than at export time the
+	highlights would land in the wrong place, they are skipped unless
+	--ignore-layout is set
+
+	a json summary of what was imported is printed to stdout, -i will indent it,
+	--summary-out also writes it to a file. The exit status is 1 if any
+	file failed, nothing is imported if any of the export files can't be
+	read (or is of a newer ghligh)
 `,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		target, err := cmd.Flags().GetString("file")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if len(args) == 0 && len(importRoots) == 0 && target == "" {
 			cmd.Help()
 			return
 		}
@@ -132,65 +1056,189 @@ var importCmd = &cobra.Command{
 			return
 		}
 
-		if stdin == false && len(inputFiles) == 0 {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if dryRun {
+			save = false
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		from := append(append([]string{}, inputFiles...), importInFiles...)
+		if stdin == false && len(from) == 0 {
 			fmt.Fprintf(os.Stderr, "nowhere to put output I am not doing anything\n")
 			return
 		}
 
 		// Load Annot Maps
-		ia := importedAnnots{
-			internal:     make(map[string]document.AnnotsMap),
-			annotsHashes: make(map[string]map[string]bool),
+		ia := newImportedAnnots()
+
+		loaded := loadImportFiles(ia, from)
+		if stdin {
+			if err := loadImportedAnnots(ia, os.Stdin); err != nil {
+				fmt.Fprintf(os.Stderr, "stdin: %v\n", err)
+				loaded = false
+			}
+		}
+		if !loaded {
+			// importing what could be read would look like a success
+			os.Exit(1)
 		}
 
-		var wg sync.WaitGroup
+		files := args
+		if target != "" {
+			files = []string{target}
+		} else if len(importRoots) > 0 {
+			pdfs, err := scanRoots(context.Background(), importRoots, cliScanOptions())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			files = append(files, pdfs...)
+		}
 
-		wg.Add(len(inputFiles))
-		for _, file := range inputFiles {
-			//wg.Add(1)
-			go func(path string) {
-				defer wg.Done()
+		fuzzy, err := cmd.Flags().GetBool("fuzzy")
+		if err != nil {
+			cmd.Help()
+			return
+		}
 
-				f, err := os.Open(path)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "could not open file %s: %v\n", path, err)
-					return
-				}
-				defer f.Close()
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			cmd.Help()
+			return
+		}
 
-				loadImportedAnnots(&ia, f)
-			}(file)
+		ignoreLayout, err := cmd.Flags().GetBool("ignore-layout")
+		if err != nil {
+			cmd.Help()
+			return
 		}
 
-		wg.Wait()
+		ignoreHash, err := cmd.Flags().GetBool("ignore-hash")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if ignoreHash && target == "" {
+			fmt.Fprintf(os.Stderr, "--ignore-hash needs --file\n")
+			os.Exit(1)
+		}
 
-		if stdin {
-			loadImportedAnnots(&ia, os.Stdin)
+		backup, err := cmd.Flags().GetBool("backup")
+		if err != nil {
+			cmd.Help()
+			return
 		}
 
-		// load from inputFiles
-		for _, file := range args {
-			doc, err := document.Open(file)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error loading %s: %v", file, err)
-				continue
-			}
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			cmd.Help()
+			return
+		}
 
-			hash := doc.HashDoc()
+		strict, err := cmd.Flags().GetBool("strict")
+		if err != nil {
+			cmd.Help()
+			return
+		}
 
-			num, err := doc.Import(ia.get(hash))
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "could not import highlights into %s: %v\n", file, err)
-			} else {
-				fmt.Fprintf(os.Stderr, "imported %d annots into %s\n", num, file)
-				if save {
-					doc.Save()
-				}
+		policyFlag, err := cmd.Flags().GetString("duplicate-policy")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		policy, err := parseDuplicatePolicy(policyFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		saveRetries, err := cmd.Flags().GetInt("save-retries")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		fileTimeout, err := cmd.Flags().GetDuration("per-file-timeout")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		outDir, err := cmd.Flags().GetString("out-dir")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		summaryOut, err := cmd.Flags().GetString("summary-out")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		only, err := cmd.Flags().GetStringArray("only")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		for _, hash := range only {
+			if ia.get(hash) == nil {
+				fmt.Fprintf(os.Stderr, "no imported document has hash %s\n", hash)
 			}
-			doc.Close()
+		}
+
+		im := &importer{
+			save:         save,
+			fuzzy:        fuzzy,
+			force:        force,
+			ignoreLayout: ignoreLayout,
+			strict:       strict,
+			duplicates:   policy,
+			saveRetries:  saveRetries,
+			fileTimeout:  fileTimeout,
+			backup:       backup,
+			password:     password,
+			concurrency:  runtime.NumCPU(),
+			targeted:     target != "",
+			ignoreHash:   ignoreHash,
+			only:         hashSet(only),
+			outDir:       outDir,
+			outRoots:     append(append([]string{}, importRoots...), "."),
+		}
+		summary := im.importAll(files, ia)
 
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(summary, "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(summary)
+		}
+		if err != nil {
+			panic(err)
 		}
+		fmt.Println(string(jsonBytes))
 
+		if summaryOut != "" {
+			if err := writeJSONToFile(append(jsonBytes, '\n'), summaryOut); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if summary.Errors > 0 {
+			fmt.Fprintf(os.Stderr, "%d files failed\n", summary.Errors)
+			os.Exit(1)
+		}
 	},
 }
 
@@ -200,4 +1248,34 @@ func init() {
 	importCmd.Flags().BoolP("stdin", "0", false, "read json from stdin")
 	importCmd.Flags().BoolP("save", "", true, "save the file with new annotation importer")
 	importCmd.Flags().StringArrayVarP(&inputFiles, "from", "f", []string{}, "files to import annots from")
+	importCmd.Flags().StringArrayVar(&importInFiles, "in", []string{}, "same as --from")
+	importCmd.Flags().Bool("dry-run", false, "same as --save=false")
+	importCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories of --root")
+	importCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "only consider files of --root matching this glob pattern (repeatable)")
+	importCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "skip paths of --root matching this glob pattern (repeatable)")
+	importCmd.Flags().StringArrayVarP(&importRoots, "root", "r", []string{}, "also import into every pdf found recursively in this directory (repeatable)")
+	importCmd.Flags().BoolP("indent", "i", false, "indent the json summary")
+	importCmd.Flags().Bool("fuzzy", false, "match documents by similar title when no hash matches")
+	importCmd.Flags().Bool("force", false, "import annotations even if already in the pdf")
+	importCmd.Flags().Bool("ignore-layout", false, "import into files matched without their hash even if their pages changed")
+	importCmd.Flags().Bool("ignore-hash", false, "with --file, import even if the hash of the file doesn't match")
+	importCmd.Flags().Int("save-retries", 3, "how many times to retry saving a file after a transient error")
+	importCmd.Flags().Duration("per-file-timeout", 0, "give up on files taking longer than this (0 waits)")
+	importCmd.Flags().String("duplicate-policy", string(duplicateAll), "when several files match the same document: all, first or error")
+	importCmd.Flags().Bool("strict", false, "fail instead of fitting annotations outside of their page")
+	importCmd.Flags().String("file", "", "import into this file only")
+	importCmd.Flags().String("password", "", "password of encrypted pdfs")
+	importCmd.Flags().Bool("backup", false, "copy every pdf to <name>.ghligh-<time>.bak.pdf before saving it")
+	importCmd.Flags().String("summary-out", "", "also write the json summary to this file")
+	importCmd.Flags().StringArray("only", []string{}, "only import the document with this hash (repeatable)")
+	importCmd.Flags().String("out-dir", "", "save the imported pdfs under this directory instead of in place")
+
+	importCmd.ValidArgsFunction = completePDFs
+	registerCompletion(importCmd, "duplicate-policy", duplicatePolicies...)
+	registerFileCompletion(importCmd, "from", "json")
+	registerFileCompletion(importCmd, "in", "json")
+	registerFileCompletion(importCmd, "file", "pdf")
+	registerFileCompletion(importCmd, "root", "")
+	registerFileCompletion(importCmd, "out-dir", "")
+	registerFileCompletion(importCmd, "summary-out", "json")
 }
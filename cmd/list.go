@@ -0,0 +1,185 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// highlights of a document grouped by page index
+type pageHighlights map[int][]document.HighlightedText
+
+func groupByPage(highlights []document.HighlightedText) pageHighlights {
+	pages := make(pageHighlights)
+	for _, h := range highlights {
+		pages[h.Page] = append(pages[h.Page], h)
+	}
+	return pages
+}
+
+// subtypeHighlights returns the annotations of am kept by sf as the
+// highlights of list, free text annotations have their own text as text
+func subtypeHighlights(am document.AnnotsMap, sf subtypeFilter) pageHighlights {
+	pages := make(pageHighlights)
+	for page, annots := range sf.filter(am) {
+		for _, annot := range annots {
+			h := document.HighlightedText{Page: page, Text: annot.Text, Contents: annot.Contents, Color: annot.Color}
+			if h.Text == "" {
+				h.Text, h.Contents = h.Contents, ""
+			}
+			pages[page] = append(pages[page], h)
+		}
+	}
+	return pages
+}
+
+func (ph pageHighlights) sortedPages() []int {
+	var pages []int
+	for page := range ph {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// indentLines prefixes every line of text with prefix
+func indentLines(text string, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix)
+}
+
+func printHighlights(path string, pages pageHighlights, depth colorDepth) {
+	fmt.Printf("%s\n", path)
+	for _, page := range pages.sortedPages() {
+		fmt.Printf("  page %d\n", page+1)
+		for _, h := range pages[page] {
+			fmt.Printf("%s\n", colorize(indentLines(h.Text, "    "), h.Color, depth))
+			if h.Contents != "" {
+				fmt.Printf("%s\n", indentLines(h.Contents, "      note: "))
+			}
+		}
+	}
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list highlights of pdf files grouped by page [json]",
+	Long: `
+	ghligh list file1.pdf file2.pdf ... [--json] [-i] [--no-color] [--subtype Underline]
+
+	will show the highlighted text of every pdf file specified, grouped by
+	page, followed by the note attached to it (if any)
+
+	--subtype (repeatable) lists the annotations of that subtype instead of
+	the highlights, one of Highlight, Underline, Squiggly, StrikeOut and
+	FreeText (case doesn't matter)
+
+	on a terminal the text is shown with the color of the highlight as
+	background (true color if COLORTERM says so, else the closest of 256 or
+	16 colors), --no-color or the NO_COLOR environment variable disable it
+
+	if --json is set the output will be in json format, -i will indent it
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			return
+		}
+
+		useJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		disableColor, err := cmd.Flags().GetBool("no-color")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		depth := noColor
+		if !disableColor {
+			depth = terminalColorDepth(os.Stdout)
+		}
+
+		subtypeFlags, err := cmd.Flags().GetStringArray("subtype")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		subtypes, err := parseSubtypeFilter(subtypeFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		jsonList := make(map[string]pageHighlights)
+		for _, arg := range args {
+			doc, err := document.Open(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				continue
+			}
+
+			var pages pageHighlights
+			if subtypes != nil {
+				annots := doc.GetAnnotsText()
+				annots.SortReadingOrder()
+				pages = subtypeHighlights(annots, subtypes)
+			} else {
+				pages = groupByPage(doc.Cat())
+			}
+			if useJSON {
+				jsonList[doc.Path] = pages
+			} else {
+				printHighlights(doc.Path, pages, depth)
+			}
+
+			doc.Close()
+		}
+
+		if !useJSON {
+			return
+		}
+
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(jsonList, "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(jsonList)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(jsonBytes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolP("json", "j", false, "print highlights as json")
+	listCmd.Flags().BoolP("indent", "i", false, "indent the json output")
+	listCmd.Flags().Bool("no-color", false, "don't color highlights with their pdf color")
+	listCmd.Flags().StringArray("subtype", []string{}, "list the annotations of this subtype, like Underline (repeatable)")
+
+	listCmd.ValidArgsFunction = completePDFs
+	registerCompletion(listCmd, "subtype", document.SubtypeNames()...)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+type mergedDoc struct {
+	doc document.GhlighDoc
+	// export files that contributed annotations, by page
+	sources map[int]map[string]bool
+}
+
+// merger unions the documents of several exports, documents are the same
+// if they have the same hash (computed with the same hash mode)
+type merger struct {
+	docs  map[string]*mergedDoc
+	order []string
+}
+
+func newMerger() *merger {
+	return &merger{docs: make(map[string]*mergedDoc)}
+}
+
+func (m *merger) add(source string, doc document.GhlighDoc) {
+	// exports made before hash modes were recorded have none, they are
+	// text hashes
+	mode, err := document.ParseHashMode(string(doc.HashMode))
+	if err != nil {
+		mode = doc.HashMode
+	}
+	key := string(mode) + ":" + doc.HashBuffer
+
+	md, ok := m.docs[key]
+	if !ok {
+		md = &mergedDoc{
+			doc:     doc,
+			sources: make(map[int]map[string]bool),
+		}
+		md.doc.Version = document.FormatVersion
+		md.doc.HashMode = mode
+		md.doc.AnnotsBuffer = make(document.AnnotsMap)
+		md.doc.PageSizes = make(map[int]document.PageSize)
+		m.docs[key] = md
+		m.order = append(m.order, key)
+	}
+
+	for page, size := range doc.PageSizes {
+		if _, ok := md.doc.PageSizes[page]; !ok {
+			md.doc.PageSizes[page] = size
+		}
+	}
+
+	for page, annots := range doc.AnnotsBuffer {
+		for _, annot := range annots {
+			if containsAnnot(md.doc.AnnotsBuffer[page], annot) {
+				continue
+			}
+			md.doc.AnnotsBuffer[page] = append(md.doc.AnnotsBuffer[page], annot)
+
+			if md.sources[page] == nil {
+				md.sources[page] = make(map[string]bool)
+			}
+			md.sources[page][source] = true
+		}
+	}
+}
+
+func containsAnnot(annots []document.AnnotJSON, annot document.AnnotJSON) bool {
+	for _, a := range annots {
+		if document.AnnotsMatch(a, annot) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflicts returns a description of every page that got distinct
+// annotations from more than one export
+func (m *merger) conflicts() []string {
+	var conflicts []string
+	for _, key := range m.order {
+		md := m.docs[key]
+
+		var pages []int
+		for page, sources := range md.sources {
+			if len(sources) > 1 {
+				pages = append(pages, page)
+			}
+		}
+		sort.Ints(pages)
+
+		for _, page := range pages {
+			var sources []string
+			for source := range md.sources[page] {
+				sources = append(sources, source)
+			}
+			sort.Strings(sources)
+
+			conflicts = append(conflicts, fmt.Sprintf("%s page %d has annotations from %s",
+				md.doc.Path, page+1, strings.Join(sources, ", ")))
+		}
+	}
+	return conflicts
+}
+
+func (m *merger) merged() []document.GhlighDoc {
+	var docs []document.GhlighDoc
+	for _, key := range m.order {
+		docs = append(docs, m.docs[key].doc)
+	}
+	return docs
+}
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "merge several export files into one [json]",
+	Long: `
+	ghligh merge a.json b.json ... [--to merged.json] [-i]
+
+	will merge the exports given as arguments, highlights of the same
+	document (same hash) are put together and duplicated ones are dropped.
+	The result, in the same format as ghligh export, is written to the
+	files given with --to or to stdout.
+
+	pages that got highlights from more than one export are reported on
+	stderr so they can be reviewed
+
+	-i will indent the json output
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		outputs, err := cmd.Flags().GetStringArray("to")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		m := newMerger()
+		for _, file := range args {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+
+			docs, err := parseExport(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+				os.Exit(1)
+			}
+
+			for _, doc := range docs {
+				m.add(file, doc)
+			}
+		}
+
+		for _, conflict := range m.conflicts() {
+			fmt.Fprintf(os.Stderr, "conflict: %s\n", conflict)
+		}
+
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(m.merged(), "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(m.merged())
+		}
+		if err != nil {
+			panic(err)
+		}
+		jsonBytes = append(jsonBytes, '\n')
+
+		for _, file := range outputs {
+			if err := writeJSONToFile(jsonBytes, file); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}
+
+		if len(outputs) == 0 {
+			os.Stdout.Write(jsonBytes)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().BoolP("indent", "i", false, "indent the json data")
+	mergeCmd.Flags().StringArrayP("to", "t", []string{}, "files to save the merged export")
+
+	mergeCmd.ValidArgsFunction = completeJSON
+	registerFileCompletion(mergeCmd, "to", "json")
+}
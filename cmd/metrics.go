@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// upper bounds, in seconds, of the buckets of the scan duration histogram
+var scanDurationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+
+// serverMetrics counts what the server does for /metrics, every method
+// can be called on nil (--metrics not set) and does nothing
+type serverMetrics struct {
+	mu sync.Mutex
+
+	exports              int64
+	exportedDocuments    int64
+	imports              int64
+	removes              int64
+	annotationsImported  int64
+	annotationsRemoved   int64
+	exportErrors         int64
+	importErrors         int64
+	scanDurationCounts   []int64
+	scanDurationSum      float64
+	scanDurationObserved int64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{scanDurationCounts: make([]int64, len(scanDurationBuckets))}
+}
+
+func (m *serverMetrics) observeScan(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := d.Seconds()
+	for i, bound := range scanDurationBuckets {
+		if seconds <= bound {
+			m.scanDurationCounts[i]++
+		}
+	}
+	m.scanDurationSum += seconds
+	m.scanDurationObserved++
+}
+
+// export counts an /export that exported n documents
+func (m *serverMetrics) export(n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exports++
+	m.exportedDocuments += int64(n)
+}
+
+func (m *serverMetrics) exportError() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exportErrors++
+}
+
+// importDone counts an /import or /remove from its summary
+func (m *serverMetrics) importDone(summary importSummary, remove bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if remove {
+		m.removes++
+	} else {
+		m.imports++
+	}
+	m.annotationsImported += int64(summary.TotalImported)
+	m.annotationsRemoved += int64(summary.TotalRemoved)
+	for _, res := range summary.Files {
+		if res.Error != "" {
+			m.importErrors++
+		}
+	}
+}
+
+// write writes the metrics in the prometheus text format
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter := func(name, help string, value int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	counter("ghligh_exports_total", "Number of /export requests.", m.exports)
+	counter("ghligh_exported_documents_total", "Number of documents exported by /export.", m.exportedDocuments)
+	counter("ghligh_imports_total", "Number of /import requests.", m.imports)
+	counter("ghligh_removes_total", "Number of /remove requests.", m.removes)
+	counter("ghligh_annotations_imported_total", "Number of annotations imported.", m.annotationsImported)
+	counter("ghligh_annotations_removed_total", "Number of annotations removed.", m.annotationsRemoved)
+	counter("ghligh_export_errors_total", "Number of files /export could not read.", m.exportErrors)
+	counter("ghligh_import_errors_total", "Number of files /import and /remove failed on.", m.importErrors)
+
+	name := "ghligh_scan_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time spent scanning the root for pdf files.\n# TYPE %s histogram\n", name, name)
+	for i, bound := range scanDurationBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), m.scanDurationCounts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, m.scanDurationObserved)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(m.scanDurationSum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, m.scanDurationObserved)
+}
+
+func (m *serverMetrics) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prepuzio/ghligh/document"
+)
+
+// the default of export --name-template
+const defaultNameTemplate = "{{.Title}}"
+
+// longest file name written by export --out-dir, in bytes, most file
+// systems allow 255 and the extension and collision suffix need room
+const maxFileNameBytes = 200
+
+// nameFields are what export --name-template can use
+type nameFields struct {
+	Title  string
+	Author string
+	// File is the name of the pdf without its extension
+	File  string
+	Hash  string
+	Pages int
+}
+
+func newNameFields(doc document.GhlighDoc) nameFields {
+	return nameFields{
+		Title:  doc.Title,
+		Author: doc.Author,
+		File:   strings.TrimSuffix(filepath.Base(doc.Path), filepath.Ext(doc.Path)),
+		Hash:   doc.HashBuffer,
+		Pages:  doc.PageCount,
+	}
+}
+
+// parseNameTemplate parses a --name-template, executing it once so that
+// unknown fields are reported before anything is exported
+func parseNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("name").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %v", err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, nameFields{}); err != nil {
+		return nil, fmt.Errorf("invalid name template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// sanitizeFileName makes name safe to use as a file name on every system:
+// path separators, characters windows refuses and control characters are
+// replaced, leading and trailing spaces and dots are dropped
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+
+	if len(name) > maxFileNameBytes {
+		cut := maxFileNameBytes
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = strings.TrimRight(name[:cut], " .")
+	}
+	return name
+}
+
+// extensions of the files written by export --out-dir
+var formatExtensions = map[string]string{
+	"json":     ".json",
+	"markdown": ".md",
+	"obsidian": ".md",
+	"csv":      ".csv",
+	"tsv":      ".tsv",
+	"readwise": ".json",
+}
+
+// writeEachDocument writes every document of docs to its own file in dir,
+// named by tmpl. Documents whose names collide (ignoring case, for case
+// insensitive file systems) get a " (2)", " (3)"... suffix in the order of
+// docs. It returns the paths written.
+func writeEachDocument(dir string, tmpl *template.Template, format string, docs []document.GhlighDoc, indent bool, delimiter rune) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	var written []string
+	for _, doc := range docs {
+		var name bytes.Buffer
+		if err := tmpl.Execute(&name, newNameFields(doc)); err != nil {
+			return written, fmt.Errorf("%s: %v", doc.Path, err)
+		}
+		base := sanitizeFileName(name.String())
+		if base == "" {
+			base = sanitizeFileName(newNameFields(doc).File)
+		}
+		if base == "" {
+			base = "untitled"
+		}
+
+		ext := formatExtensions[format]
+		fileName := base + ext
+		for n := 2; used[strings.ToLower(fileName)]; n++ {
+			fileName = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		used[strings.ToLower(fileName)] = true
+
+		var output bytes.Buffer
+		if err := writeExport(&output, format, []document.GhlighDoc{doc}, indent, delimiter); err != nil {
+			return written, err
+		}
+		path := filepath.Join(dir, fileName)
+		if err := writeJSONToFile(output.Bytes(), path); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tesseractOCR recognizes text running the tesseract command, results are
+// cached by image content so repeated exports don't run it again
+type tesseractOCR struct {
+	// tesseract language, like "eng" or "eng+ita"
+	lang string
+	// "" when there is no cache
+	cacheDir string
+}
+
+func newTesseractOCR(lang string) (*tesseractOCR, error) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return nil, fmt.Errorf("--ocr needs the tesseract command: %w", err)
+	}
+
+	t := &tesseractOCR{lang: lang}
+	if base, err := os.UserCacheDir(); err == nil {
+		dir := filepath.Join(base, "ghligh", "ocr")
+		if err := os.MkdirAll(dir, 0o700); err == nil {
+			t.cacheDir = dir
+		}
+	}
+	return t, nil
+}
+
+// recognize is a document.OCRFunc
+func (t *tesseractOCR) recognize(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var entry string
+	if t.cacheDir != "" {
+		key := sha256.Sum256(append([]byte(t.lang+"\x00"), data...))
+		entry = filepath.Join(t.cacheDir, fmt.Sprintf("%x.txt", key))
+		if text, err := os.ReadFile(entry); err == nil {
+			return string(text), nil
+		}
+	}
+
+	args := []string{path, "stdout"}
+	if t.lang != "" {
+		args = append(args, "-l", t.lang)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("tesseract", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	text := strings.TrimSpace(string(out))
+	if entry != "" {
+		// a failed write only costs running tesseract again
+		os.WriteFile(entry, []byte(text), 0o600)
+	}
+	return text, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// exportProgress counts the files processed by an in-flight export
+type exportProgress struct {
+	id        int
+	total     int
+	started   time.Time
+	processed atomic.Int64
+}
+
+type progressReport struct {
+	ID        int    `json:"id"`
+	Processed int64  `json:"processed"`
+	Total     int    `json:"total"`
+	Elapsed   string `json:"elapsed"`
+}
+
+// progressTracker keeps the exports the server is running, for /progress
+type progressTracker struct {
+	mu     sync.Mutex
+	nextID int
+	active map[int]*exportProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{active: make(map[int]*exportProgress)}
+}
+
+func (pt *progressTracker) start(total int) *exportProgress {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.nextID++
+	p := &exportProgress{id: pt.nextID, total: total, started: time.Now()}
+	pt.active[p.id] = p
+	return p
+}
+
+func (pt *progressTracker) finish(p *exportProgress) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	delete(pt.active, p.id)
+}
+
+func (pt *progressTracker) reports() []progressReport {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	reports := []progressReport{}
+	for _, p := range pt.active {
+		reports = append(reports, progressReport{
+			ID:        p.id,
+			Processed: p.processed.Load(),
+			Total:     p.total,
+			Elapsed:   time.Since(p.started).Round(time.Second).String(),
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ID < reports[j].ID
+	})
+	return reports
+}
+
+// progressHandler reports how many files every in-flight export processed
+func (pt *progressTracker) progressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]progressReport{"exports": pt.reports()})
+}
+
+// stderrProgress returns a progress callback printing "exporting n/m" on
+// stderr, or nil when stderr is not a terminal
+func stderrProgress(verb string) func(done, total int) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+
+	var mu sync.Mutex
+	return func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d files", verb, done, total)
+		if done == total {
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+	}
+}
@@ -16,9 +16,33 @@ var warnings bool
 var rootCmd = &cobra.Command{
 	Use:   "ghligh",
 	Short: "pdf highlights swiss knife",
-	Long:  `ghligh can be used to manipulate pdf files in various ways.`,
+	Long: `ghligh can be used to manipulate pdf files in various ways.
+
+	flags not given on the command line are read from GHLIGH_<FLAG>
+	environment variables (GHLIGH_AUTH_TOKEN for --auth-token) and then from
+	the config file, --config or else ghligh.yaml in the current directory
+	or in the user config directory (~/.config/ghligh on linux):
+
+		concurrency: 4
+		serve:
+		  addr: ":8080"
+		  root: /home/me/papers
+		  exclude:
+		    - drafts
+
+	top level values apply to every command having that flag, values in a
+	section only to the command it is named after. Sections and lists are
+	indented with spaces, the example can be pasted with the indentation
+	of this help: the one of the first line is ignored on every line`,
 
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(cmd, cfg); err != nil {
+			return err
+		}
 
 		if !warnings {
 			poppler.DisablePopplerWarnings()
@@ -46,4 +70,5 @@ func init() {
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.AddCommand(tag.TagCmd)
 	rootCmd.PersistentFlags().BoolVar(&warnings, "warnings", false, "show poppler warnings")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default ghligh.yaml)")
 }
@@ -0,0 +1,582 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prepuzio/ghligh/document"
+)
+
+// nginx's non standard status for requests closed by the client
+const statusClientClosedRequest = 499
+
+// scanOptions changes which files scanPDFs returns
+type scanOptions struct {
+	// descend into symlinked directories, symlinked files are always
+	// returned
+	followSymlinks bool
+	// glob patterns files must match to be returned, all of them when
+	// empty, see matchPattern
+	include []string
+	// glob patterns of paths to skip, see matchPattern
+	exclude []string
+	// also return the pdfs inside .zip archives, see archivePath
+	archives bool
+}
+
+// validatePatterns checks the syntax of glob patterns
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchPattern matches a glob pattern against rel, a slash separated path
+// relative to the scan root. Like in .gitignore a pattern without a slash
+// matches any file or directory name, while one with a slash is matched
+// against the whole relative path.
+func matchPattern(pattern string, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		for _, name := range strings.Split(rel, "/") {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	ok, _ := filepath.Match(strings.TrimPrefix(pattern, "/"), rel)
+	return ok
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSet remembers physical files, they are bucketed by a cheap key so
+// that os.SameFile only runs on likely duplicates
+type fileSet map[int64][]os.FileInfo
+
+// add returns false if info (or another path to the same file) was
+// already added
+func (fs fileSet) add(key int64, info os.FileInfo) bool {
+	for _, other := range fs[key] {
+		if os.SameFile(info, other) {
+			return false
+		}
+	}
+	fs[key] = append(fs[key], info)
+	return true
+}
+
+// how many directories are read at the same time, reads are mostly
+// waiting on the disk or the network so this is more than the cpus
+const scanWorkers = 16
+
+// walkPos is the place of a file in a depth first walk of the roots
+type walkPos struct {
+	root int
+	// path relative to the root, split in names
+	names []string
+}
+
+// before orders positions like a depth first walk of the roots in order,
+// reading directories sorted by name
+func (p walkPos) before(other walkPos) bool {
+	if p.root != other.root {
+		return p.root < other.root
+	}
+	for i := 0; i < len(p.names) && i < len(other.names); i++ {
+		if p.names[i] != other.names[i] {
+			return p.names[i] < other.names[i]
+		}
+	}
+	return len(p.names) < len(other.names)
+}
+
+// a directory waiting to be read
+type dirTask struct {
+	walkPos
+	path string
+}
+
+// visitedDir is a directory claimed by a dirTask, see scanner.visit
+type visitedDir struct {
+	info os.FileInfo
+	by   walkPos
+}
+
+// scanCandidate is a file found by the walk, before duplicates are
+// dropped. Its walkPos sorts candidates in the order of a depth first
+// walk.
+type scanCandidate struct {
+	walkPos
+	path string
+	// nil if the file couldn't be stat'ed
+	info os.FileInfo
+	// archives stand for the pdfs inside of them
+	archive bool
+	pdfs    []string
+}
+
+type scanner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   scanOptions
+	roots  []string
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// directories waiting to be read and being read
+	queue   []dirTask
+	pending int
+	err     error
+	// directories read or queued, bucketed by inode (see fileKey) since
+	// os.SameFile compares the device and the inode
+	visited map[uint64][]visitedDir
+
+	found chan scanCandidate
+}
+
+// fail stops the walk with err, only the first error is kept
+func (sc *scanner) fail(err error) {
+	sc.mu.Lock()
+	if sc.err == nil {
+		sc.err = err
+	}
+	sc.mu.Unlock()
+	sc.cancel()
+}
+
+// visit claims the directory info for the task at pos, it returns false
+// if the directory was already claimed from a position coming first in a
+// depth first walk. Each directory is read once, except when a position
+// coming first reaches it after another one: it is then read again so that
+// the paths returned don't depend on scheduling. Symlink loops always lead
+// to a position after the one they come from, and stop there.
+func (sc *scanner) visit(pos walkPos, info os.FileInfo) bool {
+	key := fileKey(info)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	dirs := sc.visited[key]
+	for i, dir := range dirs {
+		if os.SameFile(dir.info, info) {
+			if !pos.before(dir.by) {
+				return false
+			}
+			dirs[i].by = pos
+			return true
+		}
+	}
+	sc.visited[key] = append(dirs, visitedDir{info: info, by: pos})
+	return true
+}
+
+// push queues a directory to read
+func (sc *scanner) push(task dirTask) {
+	sc.mu.Lock()
+	sc.queue = append(sc.queue, task)
+	sc.pending++
+	sc.mu.Unlock()
+	sc.cond.Signal()
+}
+
+// pop returns the next directory to read, ok is false once every queued
+// directory was read
+func (sc *scanner) pop() (task dirTask, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for len(sc.queue) == 0 && sc.pending > 0 {
+		sc.cond.Wait()
+	}
+	if len(sc.queue) == 0 {
+		return dirTask{}, false
+	}
+	task = sc.queue[len(sc.queue)-1]
+	sc.queue = sc.queue[:len(sc.queue)-1]
+	return task, true
+}
+
+// done marks a directory popped with pop as read
+func (sc *scanner) done() {
+	sc.mu.Lock()
+	sc.pending--
+	last := sc.pending == 0
+	sc.mu.Unlock()
+	if last {
+		// wake up the workers waiting in pop so they can return
+		sc.cond.Broadcast()
+	}
+}
+
+func (sc *scanner) worker() {
+	for {
+		task, ok := sc.pop()
+		if !ok {
+			return
+		}
+		// once the walk failed or was cancelled the queue is only drained
+		if sc.ctx.Err() == nil {
+			if err := sc.walk(task); err != nil {
+				sc.fail(err)
+			}
+		}
+		sc.done()
+	}
+}
+
+// isDocument reports whether the file at path is a pdf to scan
+func isDocument(path string) bool {
+	if filepath.Ext(path) != ".pdf" {
+		return false
+	}
+	// backups made by import --backup are not documents of their own,
+	// neither are the pdfs being saved (or left behind by a crash while
+	// saving)
+	return !isBackup(path) && !strings.HasPrefix(filepath.Base(path), document.TempPrefix)
+}
+
+// candidate returns the scanCandidate for the file at path under root, ok
+// is false for files that aren't pdfs (or archives with opts.archives)
+func (sc *scanner) candidate(path string, root int, info os.FileInfo) (scanCandidate, bool, error) {
+	if !info.Mode().IsRegular() {
+		return scanCandidate{}, false, nil
+	}
+	isArchive := sc.opts.archives && filepath.Ext(path) == ".zip"
+	if !isArchive && !isDocument(path) {
+		return scanCandidate{}, false, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return scanCandidate{}, false, err
+	}
+
+	c := scanCandidate{walkPos: walkPos{root: root}, path: abs, info: info}
+	if rel, err := filepath.Rel(sc.roots[root], path); err == nil {
+		c.names = strings.Split(filepath.ToSlash(rel), "/")
+	}
+	if isArchive {
+		pdfs, err := archivedPDFs(abs)
+		if err != nil {
+			// not every .zip is readable, that's no reason to stop scanning
+			return scanCandidate{}, false, nil
+		}
+		c.archive = true
+		c.pdfs = pdfs
+	}
+	return c, true, nil
+}
+
+// unreadable returns the scanCandidate of a pdf that couldn't be
+// stat'ed, it has no info and is never a duplicate
+func (sc *scanner) unreadable(path string, root int) (scanCandidate, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return scanCandidate{}, err
+	}
+
+	c := scanCandidate{walkPos: walkPos{root: root}, path: abs}
+	if rel, ok := sc.relative(path, root); ok {
+		c.names = strings.Split(rel, "/")
+	}
+	return c, nil
+}
+
+// relative returns path relative to the root, as a slash separated path
+// for matchAny
+func (sc *scanner) relative(path string, root int) (string, bool) {
+	rel, err := filepath.Rel(sc.roots[root], path)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// excluded reports whether path matches one of the exclude patterns
+func (sc *scanner) excluded(path string, root int) bool {
+	if len(sc.opts.exclude) == 0 {
+		return false
+	}
+
+	rel, ok := sc.relative(path, root)
+	return ok && matchAny(sc.opts.exclude, rel)
+}
+
+// included reports whether the file at path matches one of the include
+// patterns, directories are always walked since files below them may
+func (sc *scanner) included(path string, root int) bool {
+	if len(sc.opts.include) == 0 {
+		return true
+	}
+
+	rel, ok := sc.relative(path, root)
+	return ok && matchAny(sc.opts.include, rel)
+}
+
+// walk reads the directory of task, queueing its subdirectories and
+// sending its files to sc.found
+func (sc *scanner) walk(task dirTask) error {
+	entries, err := os.ReadDir(task.path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := sc.ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(task.path, entry.Name())
+		if sc.excluded(path, task.root) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed or renamed since the directory was read
+			continue
+		}
+		if err != nil {
+			// the file is still returned so that its error is reported
+			// along with the others, instead of stopping the scan
+			if !entry.IsDir() && isDocument(path) && sc.included(path, task.root) {
+				c, err := sc.unreadable(path, task.root)
+				if err != nil {
+					return err
+				}
+				sc.found <- c
+			}
+			continue
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil {
+				// dangling symlink
+				continue
+			}
+			if target.IsDir() && !sc.opts.followSymlinks {
+				continue
+			}
+			info = target
+		}
+
+		if info.IsDir() {
+			pos := walkPos{root: task.root, names: append(task.names[:len(task.names):len(task.names)], entry.Name())}
+			if !sc.visit(pos, info) {
+				continue
+			}
+			sc.push(dirTask{walkPos: pos, path: path})
+			continue
+		}
+
+		if !sc.included(path, task.root) {
+			continue
+		}
+		c, ok, err := sc.candidate(path, task.root, info)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sc.found <- c
+		}
+	}
+
+	return nil
+}
+
+// resolveUnder joins root and the client supplied path rel, paths that
+// would end up outside of root are refused
+func resolveUnder(root string, rel string) (string, error) {
+	if filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s: path must be relative to the root", rel)
+	}
+
+	path := filepath.Join(root, rel)
+	r, err := filepath.Rel(root, path)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path outside of the root", rel)
+	}
+	return filepath.Abs(path)
+}
+
+// resolveFile is confineTo for a file under root, which may itself have
+// symlinks in its path: a file or directory under root linking outside of
+// it is refused like ../ is
+func resolveFile(root string, rel string) (string, error) {
+	base, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	base, err = filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", err
+	}
+	return confineTo(base, rel)
+}
+
+// confineTo is resolveUnder for a directory that must also stay inside of
+// base once symlinks are followed, base must already be free of them (see
+// filepath.EvalSymlinks). Paths that don't exist yet are only checked
+// lexically.
+func confineTo(base string, rel string) (string, error) {
+	path, err := resolveUnder(base, filepath.Clean(rel))
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path, nil
+	}
+	r, err := filepath.Rel(base, resolved)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path outside of the root", rel)
+	}
+	return resolved, nil
+}
+
+// scanPDFs returns the absolute path of every .pdf file under root
+// matching opts.include and not opts.exclude (backups excluded), each
+// physical file only once even if reachable from more than one path. The
+// walk stops with ctx.Err() as soon as ctx is done.
+func scanPDFs(ctx context.Context, root string, opts scanOptions) ([]string, error) {
+	return scanRoots(ctx, []string{root}, opts)
+}
+
+// scanRoots is like scanPDFs for several roots, a file found under more
+// than one of them is only returned once. Include and exclude patterns are
+// relative to the root being scanned. Directories are read by up to
+// scanWorkers goroutines, the first error (or ctx.Err()) stops the walk
+// and is returned. The order of the files and which of several paths to
+// the same file is returned don't depend on scheduling: they are those of
+// a depth first walk.
+func scanRoots(ctx context.Context, roots []string, opts scanOptions) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sc := &scanner{
+		ctx:     ctx,
+		cancel:  cancel,
+		opts:    opts,
+		roots:   roots,
+		visited: make(map[uint64][]visitedDir),
+		found:   make(chan scanCandidate),
+	}
+	sc.cond = sync.NewCond(&sc.mu)
+
+	var candidates []scanCandidate
+	for i, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() {
+			pos := walkPos{root: i}
+			if sc.visit(pos, info) {
+				sc.queue = append(sc.queue, dirTask{walkPos: pos, path: root})
+				sc.pending++
+			}
+			continue
+		}
+		c, ok, err := sc.candidate(root, i, info)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, c)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(scanWorkers)
+	for i := 0; i < scanWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			sc.worker()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(sc.found)
+	}()
+
+	for c := range sc.found {
+		candidates = append(candidates, c)
+	}
+	if sc.err != nil {
+		return nil, sc.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].before(candidates[j].walkPos)
+	})
+
+	files := make(fileSet)
+	var pdfs []string
+	for _, c := range candidates {
+		if c.info != nil && !files.add(c.info.Size(), c.info) {
+			continue
+		}
+		if c.archive {
+			pdfs = append(pdfs, c.pdfs...)
+		} else {
+			pdfs = append(pdfs, c.path)
+		}
+	}
+	return pdfs, nil
+}
+
+// modifiedSince returns the files of pdfs modified after since, pdfs
+// inside archives change with their archive. Files that can't be stat'ed
+// are left out, they would otherwise be returned on every poll.
+func modifiedSince(pdfs []string, since time.Time) []string {
+	var recent []string
+	for _, path := range pdfs {
+		file := path
+		if archive, _, ok := splitArchivePath(path); ok {
+			file = archive
+		}
+		info, err := os.Stat(file)
+		if err == nil && info.ModTime().After(since) {
+			recent = append(recent, path)
+		}
+	}
+	return recent
+}
+
+// scanError reports to the client an error returned by scanPDFs
+func scanError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		// nobody is going to read it, but it shows up in the logs
+		http.Error(w, err.Error(), statusClientClosedRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
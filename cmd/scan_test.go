@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// touch creates an empty file at root/rel, with its directories
+func touch(t *testing.T, root string, rel string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func scanRel(t *testing.T, root string, opts scanOptions) []string {
+	t.Helper()
+	pdfs, err := scanPDFs(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, path := range pdfs {
+		r, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestScanSkipsTempAndBackupFiles(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, root, "a.pdf")
+	touch(t, root, "notes.txt")
+	// a save in progress, or left behind by a crash
+	touch(t, root, ".ghligh_123456.pdf")
+	touch(t, root, "sub/.ghligh_789.pdf")
+	touch(t, root, "sub/b.pdf")
+	touch(t, root, "sub/b.ghligh-20250101-120000.bak.pdf")
+	// only the backups made by ghligh are skipped
+	touch(t, root, "sub/c.bak.pdf")
+
+	got := scanRel(t, root, scanOptions{})
+	want := []string{"a.pdf", "sub/b.pdf", "sub/c.bak.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan = %q, want %q", got, want)
+	}
+}
+
+func TestScanFollowSymlinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, root, "docs/a.pdf")
+	touch(t, root, "docs/deep/b.pdf")
+	for _, link := range []struct{ target, name string }{
+		// two paths to the same directory
+		{"docs", "x"},
+		{"docs", "y"},
+		// a loop
+		{"..", "docs/deep/up"},
+	} {
+		if err := os.Symlink(link.target, filepath.Join(root, link.name)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		got := scanRel(t, root, scanOptions{followSymlinks: true})
+		want := []string{"docs/a.pdf", "docs/deep/b.pdf"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("scan = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestScanSymlinkDiamonds(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// level i links twice to level i+1, there are 2^depth paths to the
+	// last one and each directory must still be read about once
+	const depth = 24
+	for i := 0; i < depth; i++ {
+		level := filepath.Join(root, "level"+strconv.Itoa(i))
+		if err := os.MkdirAll(level, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		next := filepath.Join("..", "level"+strconv.Itoa(i+1))
+		for _, name := range []string{"a", "b"} {
+			if err := os.Symlink(next, filepath.Join(level, name)); err != nil {
+				t.Skip("symlinks not supported:", err)
+			}
+		}
+	}
+	touch(t, root, "level"+strconv.Itoa(depth)+"/c.pdf")
+
+	got := scanRel(t, root, scanOptions{followSymlinks: true})
+	want := []string{"level0/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/a/c.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "print the JSON Schema of the export format [json]",
+	Long: `
+	ghligh schema [-i]
+
+	prints a JSON Schema (draft 2020-12) describing the json written by
+	ghligh export and read by ghligh import and the http endpoints, it is
+	generated from the types used by ghligh so it matches the version
+	being run
+
+	-i will indent the json output
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(document.ExportSchema(), "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(document.ExportSchema())
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(jsonBytes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().BoolP("indent", "i", false, "indent the json output")
+}
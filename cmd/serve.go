@@ -4,196 +4,1480 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/prepuzio/ghligh/document"
 	"github.com/spf13/cobra"
 )
 
-type importFileResult struct {
-	File     string `json:"file"`
-	Imported int    `json:"imported"`
-	Saved    bool   `json:"saved"`
-	Error    string `json:"error,omitempty"`
+// writeJSON encodes v before sending anything so that an encoding error
+// becomes a 500 and not a truncated body with status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	writeJSONIndent(w, status, v, false)
 }
 
-type importSummary struct {
-	Files         []importFileResult `json:"files"`
-	TotalImported int                `json:"totalImported"`
+// writeJSONIndent is like writeJSON, indenting the json when indent is set
+func writeJSONIndent(w http.ResponseWriter, status int, v any, indent bool) {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, v, indent); err != nil {
+		slog.Error("could not encode response", "error", err)
+		http.Error(w, "could not encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		// the client is most likely gone
+		slog.Debug("could not write response", "error", err)
+	}
 }
 
-func scanPDFs(root string) ([]string, error) {
-	var pdfs []string
-	walkFn := func(path string, d os.DirEntry, err error) error {
+// encodeJSON writes v to buf as json, indented with tabs if indent is set
+func encodeJSON(buf *bytes.Buffer, v any, indent bool) error {
+	enc := json.NewEncoder(buf)
+	if indent {
+		enc.SetIndent("", "	")
+	}
+	return enc.Encode(v)
+}
+
+// server holds the configuration shared by the http handlers
+type server struct {
+	root string
+	// directory ?root is relative to, "" refuses ?root
+	baseDir        string
+	concurrency    int
+	maxImportBytes int64
+	maxUploadBytes int64
+	saveRetries    int
+	fileTimeout    time.Duration
+	hashMode       document.HashMode
+	scan           scanOptions
+	// used to open encrypted pdfs
+	password string
+	cache    *exportCache
+	// exports and hashes of the last used pdfs, shared by every handler
+	docs *docCache
+	// write absolute paths in exports instead of paths relative to root
+	absolutePaths bool
+	logger        *slog.Logger
+	progress      *progressTracker
+	// nil unless --metrics is set
+	metrics *serverMetrics
+}
+
+// writeNDJSON writes one document per line flushing after each of them,
+// it returns how many documents were written
+func writeNDJSON(w http.ResponseWriter, docs <-chan document.GhlighDoc) int {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	var err error
+	written := 0
+	for doc := range docs {
+		// once the client is gone keep draining until the workers, stopped
+		// by the request context, exit
 		if err != nil {
-			return err
+			continue
 		}
-		if d.IsDir() {
-			return nil
+		if err = enc.Encode(doc); err != nil {
+			continue
 		}
-		if filepath.Ext(d.Name()) != ".pdf" {
-			return nil
+		written++
+		if flusher != nil {
+			flusher.Flush()
 		}
-		abs, err := filepath.Abs(path)
+	}
+	return written
+}
+
+func (s *server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	// GET so that http caches and polling clients can use the ETag
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	root, ok := s.requestRoot(w, r)
+	if !ok {
+		return
+	}
+
+	pdfs, err := s.scanRoot(r.Context(), root)
+	if err != nil {
+		scanError(w, err)
+		return
+	}
+	scanned := len(pdfs)
+
+	withText, err := queryBool(r, "text")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pages, err := parsePageRanges(r.URL.Query().Get("pages"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
 		if err != nil {
-			return err
+			http.Error(w, fmt.Sprintf("invalid since value %q, use RFC3339 like 2006-01-02T15:04:05Z", since), http.StatusBadRequest)
+			return
 		}
-		pdfs = append(pdfs, abs)
-		return nil
+		pdfs = modifiedSince(pdfs, t)
 	}
 
-	if err := filepath.WalkDir(root, walkFn); err != nil {
-		return nil, err
+	hashes := hashSet(r.URL.Query()["hash"])
+
+	colors, err := parseColorFilter(r.URL.Query()["color"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	return pdfs, nil
-}
 
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(v)
-}
+	subtypes, err := parseSubtypeFilter(r.URL.Query()["subtype"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-func serveExportHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	pretty, err := queryBool(r, "pretty")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	pdfs, err := scanPDFs(".")
+	envelope, err := queryBool(r, "envelope")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var exportedDocs []document.GhlighDoc
-	for _, path := range pdfs {
-		doc, err := document.Open(path)
+	accept := r.Header.Get("Accept")
+	wantsCSV := strings.Contains(accept, "text/csv")
+
+	progress := s.progress.start(len(pdfs))
+	defer s.progress.finish(progress)
+
+	var failed atomic.Int64
+
+	e := &exporter{
+		concurrency: s.concurrency,
+		ctx:         r.Context(),
+		withText:    withText || wantsCSV,
+		hashMode:    s.hashMode,
+		pages:       pages,
+		colors:      colors,
+		subtypes:    subtypes,
+		password:    s.password,
+		fileTimeout: s.fileTimeout,
+		cache:       s.cache,
+		docs:        s.docs,
+		hashes:      hashes,
+		relativeTo:  s.relativeTo(root),
+		onProgress: func(done, total int) {
+			progress.processed.Store(int64(done))
+		},
+		onError: func(path string, err error) {
+			failed.Add(1)
+			s.metrics.exportError()
+			s.logger.Warn("export skipped", "file", path, "reason", openErrorReason(err))
+		},
+	}
+
+	ndjson := strings.Contains(accept, "application/x-ndjson")
+
+	// everything but the stream is written to body before the headers
+	// are, so that its ETag can be compared with the one of the client
+	var docs []document.GhlighDoc
+	var body bytes.Buffer
+	contentType := "application/json"
+	if !ndjson {
+		docs = e.exportAll(pdfs)
+		if err := r.Context().Err(); err != nil {
+			scanError(w, err)
+			return
+		}
+		s.metrics.export(len(docs))
+
+		switch {
+		case wantsCSV:
+			contentType = "text/csv"
+			err = writeExport(&body, "csv", docs, false, 0)
+		case envelope:
+			if docs == nil {
+				docs = []document.GhlighDoc{}
+			}
+			skippedPages := 0
+			for _, doc := range docs {
+				skippedPages += len(doc.PageErrors)
+			}
+			err = encodeJSON(&body, exportEnvelope{
+				Scanned:      scanned,
+				Exported:     len(docs),
+				Skipped:      scanned - len(docs),
+				Errors:       int(failed.Load()),
+				SkippedPages: skippedPages,
+				Documents:    docs,
+			}, pretty)
+		default:
+			err = encodeJSON(&body, docs, pretty)
+		}
 		if err != nil {
-			// Keep it easy: skip unreadable PDFs
-			continue
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		etag := exportETag(body.Bytes())
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			// maybeGzip isn't reached, caches still need to know that
+			// the body depends on Accept-Encoding
+			w.Header().Add("Vary", "Accept-Encoding")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w, closeGzip := maybeGzip(w, r)
+	defer closeGzip()
+
+	w.Header().Set("X-Ghligh-Files-Scanned", strconv.Itoa(scanned))
+	if ndjson {
+		// streamed documents are only counted once the body is written
+		w.Header().Set("Trailer", "X-Ghligh-Files-Exported, X-Ghligh-Files-Skipped")
+		exported := writeNDJSON(w, e.stream(pdfs))
+		s.metrics.export(exported)
+		setExportCounts(w, scanned, exported)
+		return
+	}
+
+	setExportCounts(w, scanned, len(docs))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		// the status is already sent, all that can be done is log it
+		s.logger.Warn("could not send the export", "error", err)
+	}
+}
+
+// exportETag returns a weak ETag of an /export response body, weak since
+// gzip compressed bodies are not byte for byte the same
+func exportETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`W/"%x"`, sum[:16])
+}
+
+// etagMatches reports whether etag is one of the ETags of an If-None-Match
+// header, compared weakly (ignoring W/) as RFC 9110 requires
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// exportEnvelope is the /export response with ?envelope=true, the counts
+// tell an empty root from a root where nothing could be read. Skipped
+// files were filtered out or couldn't be read, Errors counts the latter.
+// SkippedPages counts the damaged pages of exported documents, listed in
+// their page_errors.
+type exportEnvelope struct {
+	Scanned      int                  `json:"scanned"`
+	Exported     int                  `json:"exported"`
+	Skipped      int                  `json:"skipped"`
+	Errors       int                  `json:"errors"`
+	SkippedPages int                  `json:"skippedPages"`
+	Documents    []document.GhlighDoc `json:"documents"`
+}
+
+// setExportCounts sets the headers telling how many of the scanned files
+// were exported, the others were skipped by ?since or ?hash or couldn't be
+// read
+func setExportCounts(w http.ResponseWriter, scanned, exported int) {
+	w.Header().Set("X-Ghligh-Files-Exported", strconv.Itoa(exported))
+	w.Header().Set("X-Ghligh-Files-Skipped", strconv.Itoa(scanned-exported))
+}
+
+func parseBool(name string, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q", name, value)
+	}
+	return b, nil
+}
+
+// queryBool parses the boolean query parameter name, false if missing
+func queryBool(r *http.Request, name string) (bool, error) {
+	return parseBool(name, r.URL.Query().Get(name))
+}
+
+// isDryRun reports whether the client asked with ?dryRun=true or with the
+// X-Dry-Run header to only count what an import would change
+func isDryRun(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("dryRun")
+	if value == "" {
+		value = r.Header.Get("X-Dry-Run")
+	}
+	return parseBool("dryRun", value)
+}
+
+// readImportBody decodes the export json posted to /import and /diff, on
+// failure the error has already been sent to the client
+func (s *server) readImportBody(w http.ResponseWriter, r *http.Request) ([]document.GhlighDoc, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return nil, false
 		}
-		doc.AnnotsBuffer = doc.GetAnnotsBuffer()
-		doc.HashBuffer = doc.HashDoc()
-		exportedDocs = append(exportedDocs, *doc)
-		doc.Close()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
 	}
 
-	writeJSON(w, http.StatusOK, exportedDocs)
+	if len(bytes.TrimSpace(body)) == 0 {
+		http.Error(w, "empty request body, expected the json array written by /export", http.StatusBadRequest)
+		return nil, false
+	}
+
+	importedDocs, err := parseExport(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return importedDocs, true
+}
+
+func (s *server) importHandler(w http.ResponseWriter, r *http.Request) {
+	s.applyImport(w, r, false)
 }
 
-func serveImportHandler(w http.ResponseWriter, r *http.Request) {
+// removeHandler takes the same body as /import and removes the matching
+// annotations instead
+func (s *server) removeHandler(w http.ResponseWriter, r *http.Request) {
+	s.applyImport(w, r, true)
+}
+
+func (s *server) applyImport(w http.ResponseWriter, r *http.Request, remove bool) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	im, err := s.queryImporter(r, remove)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var importedDocs []document.GhlighDoc
-	if err := json.Unmarshal(body, &importedDocs); err != nil {
-		http.Error(w, fmt.Sprintf("invalid json: %v", err), http.StatusBadRequest)
+	asPDF, err := queryBool(r, "pdf")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if asPDF && !im.save {
+		http.Error(w, "?pdf=true can't be used with ?dryRun=true", http.StatusBadRequest)
 		return
 	}
 
-	byHash := make(map[string]document.AnnotsMap)
-	for _, d := range importedDocs {
-		if d.HashBuffer == "" || d.AnnotsBuffer == nil {
-			continue
+	if isMultipart(r) {
+		s.uploadImport(w, r, im, asPDF)
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	if asPDF && file == "" {
+		http.Error(w, "?pdf=true needs ?file= or a multipart upload with a single pdf", http.StatusBadRequest)
+		return
+	}
+
+	root, ok := s.requestRoot(w, r)
+	if !ok {
+		return
+	}
+
+	importedDocs, ok := s.readImportBody(w, r)
+	if !ok {
+		return
+	}
+
+	ia := newImportedAnnots()
+	ia.load(importedDocs)
+
+	var pdfs []string
+	if file != "" {
+		path, err := resolveFile(root, file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
 		}
-		if byHash[d.HashBuffer] == nil {
-			byHash[d.HashBuffer] = make(document.AnnotsMap)
+		if _, err := os.Stat(path); err != nil {
+			http.Error(w, fmt.Sprintf("%s: no such file", file), http.StatusNotFound)
+			return
 		}
-		for page, annots := range d.AnnotsBuffer {
-			byHash[d.HashBuffer][page] = append(byHash[d.HashBuffer][page], annots...)
+		if asPDF {
+			s.importCopy(w, im, ia, path, file)
+			return
+		}
+		pdfs = []string{path}
+		im.targeted = true
+	} else {
+		pdfs, err = s.scanRoot(r.Context(), root)
+		if err != nil {
+			scanError(w, err)
+			return
 		}
 	}
 
-	pdfs, err := scanPDFs(".")
+	summary := im.importAll(pdfs, ia)
+	s.logImport(summary)
+	s.metrics.importDone(summary, remove)
+
+	writeJSON(w, importStatus(summary), summary)
+}
+
+// importStatus is the status of an /import or /remove response: 200 when
+// no file failed, 500 when every file did and 207 (multi-status) when only
+// some of them did, the summary tells which
+func importStatus(summary importSummary) int {
+	switch {
+	case summary.Errors == 0:
+		return http.StatusOK
+	case summary.Errors == len(summary.Files):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusMultiStatus
+	}
+}
+
+// queryImporter returns an importer set up from the query parameters of
+// an /import or /remove request
+func (s *server) queryImporter(r *http.Request, remove bool) (*importer, error) {
+	dryRun, err := isDryRun(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	summary := importSummary{}
-	for _, path := range pdfs {
-		res := importFileResult{File: path}
+	fuzzy, err := queryBool(r, "fuzzy")
+	if err != nil {
+		return nil, err
+	}
 
-		doc, err := document.Open(path)
-		if err != nil {
-			res.Error = err.Error()
-			summary.Files = append(summary.Files, res)
+	// removing annotations already in the pdf is the point of /remove
+	if remove && r.URL.Query().Has("force") {
+		return nil, fmt.Errorf("?force can't be used with /remove")
+	}
+	force, err := queryBool(r, "force")
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreHash, err := queryBool(r, "ignoreHash")
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreLayout, err := queryBool(r, "ignoreLayout")
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := queryBool(r, "backup")
+	if err != nil {
+		return nil, err
+	}
+
+	strict, err := queryBool(r, "strict")
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := parseDuplicatePolicy(r.URL.Query().Get("duplicatePolicy"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &importer{
+		save:         !dryRun,
+		fuzzy:        fuzzy,
+		force:        force,
+		ignoreLayout: ignoreLayout,
+		ignoreHash:   ignoreHash,
+		strict:       strict,
+		duplicates:   policy,
+		remove:       remove,
+		backup:       backup,
+		password:     s.password,
+		concurrency:  s.concurrency,
+		saveRetries:  s.saveRetries,
+		fileTimeout:  s.fileTimeout,
+		docs:         s.docs,
+		only:         hashSet(r.URL.Query()["hash"]),
+	}, nil
+}
+
+// logImport logs the result of every file of an import
+func (s *server) logImport(summary importSummary) {
+	for _, res := range summary.Files {
+		if res.Error != "" {
+			s.logger.Warn("import failed", "file", res.File, "error", res.Error)
 			continue
 		}
+		s.logger.Debug("import", "file", res.File, "imported", res.Imported,
+			"removed", res.Removed, "saved", res.Saved, "skipped", res.Skipped)
+	}
+}
+
+type diffFileResult struct {
+	File  string                    `json:"file"`
+	Pages map[int]document.PageDiff `json:"pages"`
+	Error string                    `json:"error,omitempty"`
+}
+
+type diffSummary struct {
+	Files []diffFileResult `json:"files"`
+}
+
+func (s *server) diffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	root, ok := s.requestRoot(w, r)
+	if !ok {
+		return
+	}
+
+	importedDocs, ok := s.readImportBody(w, r)
+	if !ok {
+		return
+	}
+
+	ia := newImportedAnnots()
+	ia.load(importedDocs)
+
+	pdfs, err := s.scanRoot(r.Context(), root)
+	if err != nil {
+		scanError(w, err)
+		return
+	}
 
-		h := doc.HashDoc()
-		am := byHash[h]
-		if am == nil {
-			doc.Close()
+	summary := diffSummary{}
+	for _, path := range pdfs {
+		if s.docs.knownMiss(ia, path) {
 			continue
 		}
-
-		imported, err := doc.Import(am)
-		res.Imported = imported
-		if err != nil {
-			res.Error = err.Error()
-			doc.Close()
+		res := s.diffFile(path, ia)
+		if res.Error != "" || res.Pages != nil {
 			summary.Files = append(summary.Files, res)
-			continue
 		}
+	}
 
-		if imported > 0 {
-			saved, err := doc.Save()
-			res.Saved = saved
-			if err != nil {
-				res.Error = err.Error()
-			}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *server) diffFile(path string, ia *importedAnnots) (res diffFileResult) {
+	res.File = path
+	defer func() {
+		if r := recover(); r != nil {
+			res.Pages = nil
+			res.Error = fmt.Sprintf("panic: %v", r)
 		}
+	}()
 
-		doc.Close()
-		summary.TotalImported += imported
-		summary.Files = append(summary.Files, res)
+	doc, err := openPDF(path, s.password)
+	if err != nil {
+		res.Error = err.Error()
+		return res
 	}
+	defer doc.Close()
 
-	writeJSON(w, http.StatusOK, summary)
+	_, am, err := s.docs.lookupHash(ia, doc, path)
+	if err != nil {
+		res.Error = err.Error()
+	} else if len(am) > 0 {
+		res.Pages = doc.Diff(am)
+	}
+	return res
 }
 
-var serveCmd = &cobra.Command{
-	Use:   "serve",
-	Short: "serve http import/export endpoints",
-	Long: `
-	ghligh serve [--addr :8080]
+// documentEntry describes a document for /documents, without annotations
+type documentEntry struct {
+	Path       string `json:"path"`
+	Hash       string `json:"hash"`
+	Title      string `json:"title"`
+	AnnotCount int    `json:"annotCount"`
+}
 
-	Starts a simple HTTP server with:
-	- POST /export : export highlights recursively under cwd
-	- POST /import : import highlights (export JSON format) into PDFs under cwd
-`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		addr, err := cmd.Flags().GetString("addr")
-		if err != nil {
-			return err
-		}
+// documentsHandler lists the documents under the root, their full export
+// can then be fetched with /export?hash=
+func (s *server) documentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		mux := http.NewServeMux()
-		mux.HandleFunc("/export", serveExportHandler)
-		mux.HandleFunc("/import", serveImportHandler)
+	root, ok := s.requestRoot(w, r)
+	if !ok {
+		return
+	}
 
-		srv := &http.Server{Addr: addr, Handler: mux}
-		fmt.Fprintf(os.Stderr, "listening on %s\n", addr)
-		return srv.ListenAndServe()
-	},
+	pdfs, err := s.scanRoot(r.Context(), root)
+	if err != nil {
+		scanError(w, err)
+		return
+	}
+
+	e := &exporter{
+		concurrency: s.concurrency,
+		ctx:         r.Context(),
+		hashMode:    s.hashMode,
+		password:    s.password,
+		fileTimeout: s.fileTimeout,
+		cache:       s.cache,
+		docs:        s.docs,
+		relativeTo:  s.relativeTo(root),
+		onError: func(path string, err error) {
+			s.logger.Warn("document skipped", "file", path, "reason", openErrorReason(err))
+		},
+	}
+
+	docs := e.exportAll(pdfs)
+	if err := r.Context().Err(); err != nil {
+		scanError(w, err)
+		return
+	}
+
+	entries := []documentEntry{}
+	for _, doc := range docs {
+		entry := documentEntry{Path: doc.Path, Hash: doc.HashBuffer, Title: doc.Title}
+		for _, annots := range doc.AnnotsBuffer {
+			entry.AnnotCount += len(annots)
+		}
+		entries = append(entries, entry)
+	}
+
+	writeJSON(w, http.StatusOK, entries)
 }
 
-func init() {
-	rootCmd.AddCommand(serveCmd)
-	serveCmd.Flags().String("addr", ":6969", "http listen address")
+// requestRoot returns the directory r works on: --root, or with --base-dir
+// the ?root directory relative to it. Roots escaping the base directory,
+// with ../ or symlinks, are refused with a 403. On failure the error has
+// already been sent to the client.
+func (s *server) requestRoot(w http.ResponseWriter, r *http.Request) (string, bool) {
+	rel := r.URL.Query().Get("root")
+	if rel == "" {
+		return s.root, true
+	}
+	if s.baseDir == "" {
+		http.Error(w, "?root is only accepted when the server runs with --base-dir", http.StatusForbidden)
+		return "", false
+	}
+
+	root, err := confineTo(s.baseDir, rel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return "", false
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		http.Error(w, fmt.Sprintf("%s: no such directory", rel), http.StatusNotFound)
+		return "", false
+	}
+	return root, true
+}
+
+// scanRoot scans root for pdf files
+func (s *server) scanRoot(ctx context.Context, root string) ([]string, error) {
+	start := time.Now()
+	pdfs, err := scanPDFs(ctx, root, s.scan)
+	s.metrics.observeScan(time.Since(start))
+	return pdfs, err
+}
+
+// relativeTo is exporter.relativeTo for the exports of root
+func (s *server) relativeTo(root string) []string {
+	if s.absolutePaths {
+		return nil
+	}
+	return []string{root}
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// requireToken rejects with 401 every request not carrying
+// "Authorization: Bearer <token>"
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte(token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ghligh"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers what a handler wrote for logRequests
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// gzipResponseWriter compresses what is written to it, Flush flushes the
+// compressed data written so far so that ndjson still streams
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+// WriteHeader drops the Content-Length set by handlers, it is the one of
+// the uncompressed body
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	g.Header().Del("Content-Length")
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+func (g *gzipResponseWriter) Flush() {
+	g.gz.Flush()
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+				continue
+			}
+			// "gzip;q=0" means gzip is not acceptable
+			if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				q, err := strconv.ParseFloat(value, 64)
+				return err == nil && q > 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// maybeGzip wraps w in a gzipResponseWriter if the client accepts gzip,
+// the returned func must be called once the response is written
+func maybeGzip(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		return w, func() {}
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	gz := gzip.NewWriter(w)
+	return &gzipResponseWriter{ResponseWriter: w, gz: gz}, func() {
+		gz.Close()
+	}
+}
+
+func logRequests(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// newLogger returns a logger writing to stderr in the given format, text
+// (key=value pairs) or json (one object per line)
+func newLogger(format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: use text or json", format)
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// normalizeAddr validates a listen address, a bare port like 6969 is
+// turned into :6969
+func normalizeAddr(addr string) (string, error) {
+	if _, err := strconv.ParseUint(addr, 10, 16); err == nil {
+		addr = ":" + addr
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid --addr %q, use host:port, :port or port: %v", addr, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return "", fmt.Errorf("invalid --addr %q: bad port %q", addr, port)
+	}
+	return addr, nil
+}
+
+// serverURL is the url clients can use to reach a server listening on
+// addr, an empty host (every interface) is shown as localhost
+func serverURL(scheme string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return scheme + "://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// serveUntilSignal runs listen until it fails or until SIGINT/SIGTERM is
+// received, in which case in-flight requests (an /import calling Save() for
+// instance) are given up to timeout to complete before returning.
+func serveUntilSignal(srv *http.Server, listen func() error, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- listen()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	// a second signal kills the process right away
+	stop()
+
+	fmt.Fprintf(os.Stderr, "shutting down, waiting up to %s for in-flight requests\n", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("in-flight requests did not complete within %s, shutdown forced", timeout)
+		}
+		return err
+	}
+
+	return nil
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve http import/export endpoints",
+	Long: `
+	ghligh serve [--addr :8080] [--shutdown-timeout 30s] [--tls-cert cert.pem --tls-key key.pem]
+		[--auth-token secret] [--root dir] [--base-dir dir] [--log-level info] [--log-format text|json]
+		[--concurrency n] [--hash-mode text|bytes] [--follow-symlinks] [--include pattern]
+		[--exclude pattern] [--zip] [--password secret] [--no-cache] [--doc-cache-size 1024] [--absolute-paths] [--metrics]
+		[--read-header-timeout 10s] [--read-timeout 5m] [--write-timeout 0] [--idle-timeout 2m]
+		[--rate-limit 2 [--rate-burst 5]]
+
+	Starts a simple HTTP server with:
+	- POST /export : export highlights recursively under --root, with
+	                 "Accept: application/x-ndjson" documents are streamed
+	                 one per line as soon as they are processed, with
+	                 "Accept: text/csv" one row per annotation is returned,
+	                 ?text=true also exports the highlighted text and
+	                 ?pages=5-20,33 only exports those pages,
+	                 ?since=2025-01-02T15:04:05Z only the files modified
+	                 after that time (RFC3339), ?hash=h (repeatable)
+	                 only the documents with that hash and ?color=red
+	                 (repeatable, names or hex like ghligh export
+	                 --color) only the annotations of that color,
+	                 ?subtype=Underline (repeatable, like ghligh export
+	                 --subtype) only the annotations of that subtype,
+	                 ?pretty=true indents the json like ghligh export -i,
+	                 ?envelope=true returns {"scanned", "exported",
+	                 "skipped", "errors", "skippedPages", "documents"}
+	                 instead of the bare array, "errors" counts the
+	                 skipped files that couldn't be read and
+	                 "skippedPages" the damaged pages of the exported
+	                 ones (see "page_errors" of ghligh export),
+	                 the response is gzip compressed if the client sends
+	                 "Accept-Encoding: gzip". The X-Ghligh-Files-Scanned,
+	                 X-Ghligh-Files-Exported and X-Ghligh-Files-Skipped
+	                 headers count the pdfs found under --root, the ones
+	                 exported and the ones left out (filtered or not
+	                 readable), when streaming the last two are trailers.
+	                 Responses but streamed ones have a weak ETag that
+	                 changes with their body (counts included), sending it back
+	                 in If-None-Match gets a 304 Not Modified without a
+	                 body, GET works like POST for clients polling it
+	- POST /import : import highlights (export JSON format) into PDFs under --root,
+	                 with ?dryRun=true (or "X-Dry-Run: true") nothing is saved
+	                 and the summary only tells what would be imported, bodies
+	                 bigger than --max-import-bytes are refused with a 413,
+	                 documents with an export format version newer than
+	                 this ghligh understands with a 400,
+	                 ?fuzzy=true, ?ignoreLayout=true, ?force=true,
+	                 ?backup=true and ?strict=true work like ghligh
+	                 import --fuzzy, --ignore-layout, --force, --backup
+	                 and --strict,
+	                 ?file=path/in/root.pdf only imports into that file
+	                 without scanning --root, like ghligh import --file,
+	                 with ?ignoreHash=true like --ignore-hash. The file
+	                 must stay under --root once symlinks are followed,
+	                 ?duplicatePolicy=all|first|error works like
+	                 ghligh import --duplicate-policy, ?hash=h
+	                 (repeatable) only imports the documents with that
+	                 hash like ghligh import --only. Posted as
+	                 multipart/form-data (the export json as the
+	                 "export" part, pdf files as the others, up to
+	                 --max-upload-bytes) it imports into the uploaded
+	                 pdfs instead of --root and returns them in a zip
+	                 with the summary as summary.json, or just the
+	                 summary with ?dryRun=true. With ?pdf=true and
+	                 either ?file= or a single uploaded pdf the modified
+	                 pdf is sent back as application/pdf instead,
+	                 leaving the one under --root untouched, with the
+	                 imported (or removed) count in the
+	                 X-Ghligh-Imported (X-Ghligh-Removed) header and the
+	                 summary as json if it failed. The status is 200 if no
+	                 file failed, 500 if all of them did and 207 if only
+	                 some did, "errors" in the summary counts them
+	- POST /remove : takes the same json as /import and removes the matching
+	                 annotations from the PDFs under --root, like ghligh
+	                 clean. It takes the parameters of /import but ?force,
+	                 which is refused with a 400
+	- POST /diff   : takes the same json as /import and returns, for every
+	                 matching pdf, how many annotations per page the import
+	                 would add and how many the pdf has that the json lacks
+	- GET /documents: lists the documents under --root with their path,
+	                 hash, title and number of annotations but without the
+	                 annotations, fetch them with /export?hash=
+	- GET /progress: for every /export in flight, how many of its files
+	                 were processed so far and how long it has been running
+	- GET /metrics : with --metrics, counters of the exports, imports,
+	                 imported annotations and errors and a histogram of
+	                 the scan durations in the prometheus text format
+	- GET /health  : returns {"status":"ok"}, for readiness probes
+
+	--root defaults to the current directory, symlinked directories inside
+	it are only scanned with --follow-symlinks, every pdf file is processed
+	once even if reachable from more than one path
+
+	with --base-dir /export, /import, /remove, /diff and /documents take
+	?root=dir, relative to the base directory, to work on that directory instead of --root (which then defaults to the base
+	directory). Roots leading outside of it, with ../ or through symlinks,
+	are refused with a 403, without --base-dir ?root is always refused
+
+	--zip also scans the pdfs inside .zip archives, /export returns them
+	like ghligh export --zip does and /import skips them as read only
+
+	--include (repeatable) only considers the files matching one of the glob
+	patterns, relative to --root and before --exclude is applied, so
+	--include "papers" --exclude "*draft*" serves the papers directories
+	without drafts
+
+	--exclude (repeatable) skips paths matching a glob pattern, relative to
+	--root: like in .gitignore "backup" or "*.bak.pdf" match a name anywhere
+	in the tree while "old/*/draft.pdf" is matched against the whole path
+
+	--addr is host:port, :port to listen on every interface or just the
+	port
+
+	if --tls-cert and --tls-key are both set the server speaks https only
+
+	if --auth-token is set every request but /health must carry an
+	"Authorization: Bearer <token>" header
+
+	--hash-mode is used by /export like in ghligh export, /import uses the
+	mode recorded in the uploaded json
+
+	/export caches exports like ghligh export does, --no-cache disables it
+
+	the exports and hashes of the last --doc-cache-size pdfs are also kept
+	in memory, so an /export followed by /diff and /import doesn't open and
+	hash every file again. Entries go away when a file changes or is saved
+	by /import, 0 disables it
+
+	/export and /documents write file paths relative to --root, so the
+	layout of the server doesn't leak and exports work on other machines,
+	--absolute-paths writes them absolute instead
+
+	--password opens encrypted pdfs, the ones that can't be opened are
+	logged as skipped by /export and reported as errors by /import
+
+	--rate-limit caps the requests per second accepted by every endpoint
+	but /health, all clients together, with bursts of up to --rate-burst
+	requests (by default the rate rounded up), the others get a 429 with a
+	Retry-After header. It is off by default (0)
+
+	--save-retries and --per-file-timeout work like in ghligh import, files
+	timing out are logged as skipped by /export
+
+	--concurrency sets how many pdf files are processed at the same time by
+	every endpoint, it defaults to the number of cpus
+
+	every request is logged to stderr at the info level, use --log-level
+	warn to silence them, debug also logs the result of every imported
+	file, --log-format json writes one json object per event
+
+	clients have --read-header-timeout to send the request headers and
+	--read-timeout to send the whole request, idle keep-alive connections
+	are closed after --idle-timeout. --write-timeout limits the time to
+	write a response, it is disabled by default (0) because an /export of a
+	big library can take much longer than any sane limit
+
+	on SIGINT/SIGTERM the server stops accepting connections and waits up to
+	--shutdown-timeout for in-flight requests to complete
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addrFlag, err := cmd.Flags().GetString("addr")
+		if err != nil {
+			return err
+		}
+
+		addr, err := normalizeAddr(addrFlag)
+		if err != nil {
+			return err
+		}
+
+		shutdownTimeout, err := cmd.Flags().GetDuration("shutdown-timeout")
+		if err != nil {
+			return err
+		}
+
+		readHeaderTimeout, err := cmd.Flags().GetDuration("read-header-timeout")
+		if err != nil {
+			return err
+		}
+
+		readTimeout, err := cmd.Flags().GetDuration("read-timeout")
+		if err != nil {
+			return err
+		}
+
+		writeTimeout, err := cmd.Flags().GetDuration("write-timeout")
+		if err != nil {
+			return err
+		}
+
+		idleTimeout, err := cmd.Flags().GetDuration("idle-timeout")
+		if err != nil {
+			return err
+		}
+
+		tlsCert, err := cmd.Flags().GetString("tls-cert")
+		if err != nil {
+			return err
+		}
+
+		tlsKey, err := cmd.Flags().GetString("tls-key")
+		if err != nil {
+			return err
+		}
+
+		if (tlsCert == "") != (tlsKey == "") {
+			return fmt.Errorf("--tls-cert and --tls-key must be set together")
+		}
+
+		authToken, err := cmd.Flags().GetString("auth-token")
+		if err != nil {
+			return err
+		}
+
+		root, err := cmd.Flags().GetString("root")
+		if err != nil {
+			return err
+		}
+
+		baseDir, err := cmd.Flags().GetString("base-dir")
+		if err != nil {
+			return err
+		}
+		if baseDir != "" {
+			// symlinks resolved once so confineTo compares real paths
+			baseDir, err = filepath.Abs(baseDir)
+			if err == nil {
+				baseDir, err = filepath.EvalSymlinks(baseDir)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid base dir: %v", err)
+			}
+			if !cmd.Flags().Changed("root") {
+				root = baseDir
+			}
+		}
+
+		info, err := os.Stat(root)
+		if err != nil {
+			return fmt.Errorf("invalid root: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid root: %s is not a directory", root)
+		}
+
+		logLevel, err := cmd.Flags().GetString("log-level")
+		if err != nil {
+			return err
+		}
+
+		level, err := parseLogLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		logFormat, err := cmd.Flags().GetString("log-format")
+		if err != nil {
+			return err
+		}
+
+		logger, err := newLogger(logFormat, level)
+		if err != nil {
+			return err
+		}
+		// for the helpers that don't have the server at hand
+		slog.SetDefault(logger)
+
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			return err
+		}
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+
+		maxImportBytes, err := cmd.Flags().GetInt64("max-import-bytes")
+		if err != nil {
+			return err
+		}
+		if maxImportBytes < 1 {
+			return fmt.Errorf("--max-import-bytes must be at least 1")
+		}
+
+		maxUploadBytes, err := cmd.Flags().GetInt64("max-upload-bytes")
+		if err != nil {
+			return err
+		}
+		if maxUploadBytes < 1 {
+			return fmt.Errorf("--max-upload-bytes must be at least 1")
+		}
+
+		saveRetries, err := cmd.Flags().GetInt("save-retries")
+		if err != nil {
+			return err
+		}
+		if saveRetries < 0 {
+			return fmt.Errorf("--save-retries can't be negative")
+		}
+
+		fileTimeout, err := cmd.Flags().GetDuration("per-file-timeout")
+		if err != nil {
+			return err
+		}
+
+		hashModeFlag, err := cmd.Flags().GetString("hash-mode")
+		if err != nil {
+			return err
+		}
+
+		hashMode, err := document.ParseHashMode(hashModeFlag)
+		if err != nil {
+			return err
+		}
+
+		followSymlinks, err := cmd.Flags().GetBool("follow-symlinks")
+		if err != nil {
+			return err
+		}
+
+		include, err := cmd.Flags().GetStringArray("include")
+		if err != nil {
+			return err
+		}
+		if err := validatePatterns(include); err != nil {
+			return err
+		}
+
+		exclude, err := cmd.Flags().GetStringArray("exclude")
+		if err != nil {
+			return err
+		}
+		if err := validatePatterns(exclude); err != nil {
+			return err
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			return err
+		}
+
+		noCache, err := cmd.Flags().GetBool("no-cache")
+		if err != nil {
+			return err
+		}
+
+		var cache *exportCache
+		if !noCache {
+			cache, err = openExportCache()
+			if err != nil {
+				logger.Warn("not using the cache", "error", err)
+			}
+		}
+
+		archives, err := cmd.Flags().GetBool("zip")
+		if err != nil {
+			return err
+		}
+
+		enableMetrics, err := cmd.Flags().GetBool("metrics")
+		if err != nil {
+			return err
+		}
+
+		absolutePaths, err := cmd.Flags().GetBool("absolute-paths")
+		if err != nil {
+			return err
+		}
+
+		rateLimitFlag, err := cmd.Flags().GetFloat64("rate-limit")
+		if err != nil {
+			return err
+		}
+		if rateLimitFlag < 0 {
+			return fmt.Errorf("--rate-limit can't be negative")
+		}
+
+		rateBurst, err := cmd.Flags().GetInt("rate-burst")
+		if err != nil {
+			return err
+		}
+		if rateBurst < 0 {
+			return fmt.Errorf("--rate-burst can't be negative")
+		}
+
+		docCacheSize, err := cmd.Flags().GetInt("doc-cache-size")
+		if err != nil {
+			return err
+		}
+		if docCacheSize < 0 {
+			return fmt.Errorf("--doc-cache-size can't be negative")
+		}
+
+		s := &server{
+			scan: scanOptions{
+				followSymlinks: followSymlinks,
+				include:        include,
+				exclude:        exclude,
+				archives:       archives,
+			},
+			root:           root,
+			baseDir:        baseDir,
+			concurrency:    concurrency,
+			maxImportBytes: maxImportBytes,
+			maxUploadBytes: maxUploadBytes,
+			saveRetries:    saveRetries,
+			fileTimeout:    fileTimeout,
+			hashMode:       hashMode,
+			password:       password,
+			cache:          cache,
+			docs:           newDocCache(docCacheSize),
+			absolutePaths:  absolutePaths,
+			logger:         logger,
+			progress:       newProgressTracker(),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/export", s.exportHandler)
+		mux.HandleFunc("/import", s.importHandler)
+		mux.HandleFunc("/remove", s.removeHandler)
+		mux.HandleFunc("/diff", s.diffHandler)
+		mux.HandleFunc("/documents", s.documentsHandler)
+		mux.HandleFunc("/progress", s.progress.progressHandler)
+		if enableMetrics {
+			s.metrics = newServerMetrics()
+			mux.HandleFunc("/metrics", s.metrics.metricsHandler)
+		}
+
+		var api http.Handler = mux
+		if rateLimitFlag > 0 {
+			api = rateLimit(newTokenBucket(rateLimitFlag, rateBurst), api)
+		}
+		if authToken != "" {
+			api = requireToken(authToken, api)
+		}
+
+		// /health stays outside of auth so probes don't need the token
+		handler := http.NewServeMux()
+		handler.HandleFunc("/health", healthHandler)
+		handler.Handle("/", api)
+
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           logRequests(logger, handler),
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+		}
+
+		if tlsCert == "" {
+			fmt.Fprintf(os.Stderr, "listening on %s\n", serverURL("http", addr))
+			return serveUntilSignal(srv, srv.ListenAndServe, shutdownTimeout)
+		}
+
+		// load the pair now so a bad cert fails before binding and not
+		// at the first handshake
+		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+		if err != nil {
+			return fmt.Errorf("could not load tls certificate: %v", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+		fmt.Fprintf(os.Stderr, "listening on %s\n", serverURL("https", addr))
+		return serveUntilSignal(srv, func() error {
+			return srv.ListenAndServeTLS("", "")
+		}, shutdownTimeout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("addr", ":6969", "http listen address")
+	serveCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "how long to wait for in-flight requests on shutdown")
+	serveCmd.Flags().Duration("read-header-timeout", 10*time.Second, "how long clients have to send the request headers")
+	serveCmd.Flags().Duration("read-timeout", 5*time.Minute, "how long clients have to send the whole request, 0 for no limit")
+	serveCmd.Flags().Duration("write-timeout", 0, "how long writing a response can take, 0 for no limit")
+	serveCmd.Flags().Duration("idle-timeout", 2*time.Minute, "how long idle keep-alive connections are kept open")
+	serveCmd.Flags().String("tls-cert", "", "tls certificate file (PEM)")
+	serveCmd.Flags().String("tls-key", "", "tls private key file (PEM)")
+	serveCmd.Flags().String("auth-token", "", "require this bearer token on every request")
+	serveCmd.Flags().String("root", ".", "directory scanned for pdf files")
+	serveCmd.Flags().String("base-dir", "", "let requests choose their root with ?root, inside of this directory")
+	serveCmd.Flags().String("log-level", "info", "log level (debug, info, warn, error)")
+	serveCmd.Flags().String("log-format", "text", "log format (text, json)")
+	serveCmd.Flags().Int("concurrency", runtime.NumCPU(), "number of pdf files processed in parallel")
+	serveCmd.Flags().Float64("rate-limit", 0, "requests per second accepted, 0 for no limit")
+	serveCmd.Flags().Int("rate-burst", 0, "requests accepted at once above --rate-limit, 0 for the rate rounded up")
+	serveCmd.Flags().Int64("max-import-bytes", 64<<20, "maximum size of an /import request body")
+	serveCmd.Flags().Int("save-retries", 3, "how many times /import retries saving a file after a transient error")
+	serveCmd.Flags().Duration("per-file-timeout", 0, "give up on files taking longer than this (0 waits)")
+	serveCmd.Flags().Int64("max-upload-bytes", 256<<20, "maximum size of a multipart /import upload")
+	serveCmd.Flags().Bool("zip", false, "also scan the pdfs inside .zip archives")
+	serveCmd.Flags().Bool("follow-symlinks", false, "descend into symlinked directories while scanning")
+	serveCmd.Flags().StringArray("include", []string{}, "only consider files matching this glob pattern (repeatable)")
+	serveCmd.Flags().StringArray("exclude", []string{}, "skip paths matching this glob pattern (repeatable)")
+	serveCmd.Flags().Bool("metrics", false, "serve counters in the prometheus text format on /metrics")
+	serveCmd.Flags().Bool("absolute-paths", false, "write absolute file paths in exports instead of paths relative to --root")
+	serveCmd.Flags().Bool("no-cache", false, "read every pdf on /export instead of using cached exports")
+	serveCmd.Flags().Int("doc-cache-size", 1024, "how many pdfs have their exports and hashes kept in memory, 0 disables it")
+	serveCmd.Flags().String("password", "", "password of encrypted pdfs")
+	serveCmd.Flags().String("hash-mode", string(document.HashText), "how /export identifies documents (text, bytes)")
+
+	registerCompletion(serveCmd, "hash-mode", hashModeNames()...)
+	registerCompletion(serveCmd, "log-level", "debug", "info", "warn", "error")
+	registerCompletion(serveCmd, "log-format", "text", "json")
+	registerFileCompletion(serveCmd, "tls-cert", "pem")
+	registerFileCompletion(serveCmd, "tls-key", "pem")
+	registerFileCompletion(serveCmd, "root", "")
+	registerFileCompletion(serveCmd, "base-dir", "")
 }
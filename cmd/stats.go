@@ -0,0 +1,183 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+type libraryStats struct {
+	Files       int            `json:"files"`
+	Annotated   int            `json:"annotated"`
+	Errors      int            `json:"errors"`
+	Annotations int            `json:"annotations"`
+	BySubtype   map[string]int `json:"bySubtype"`
+	ByColor     map[string]int `json:"byColor"`
+}
+
+func newLibraryStats() *libraryStats {
+	return &libraryStats{
+		BySubtype: make(map[string]int),
+		ByColor:   make(map[string]int),
+	}
+}
+
+func (ls *libraryStats) add(doc document.GhlighDoc) {
+	if len(doc.AnnotsBuffer) > 0 {
+		ls.Annotated++
+	}
+	for _, annots := range doc.AnnotsBuffer {
+		for _, annot := range annots {
+			ls.Annotations++
+			ls.BySubtype[annot.Subtype]++
+
+			color := document.ColorHex(annot.Color)
+			if color == "" {
+				color = "none"
+			}
+			ls.ByColor[color]++
+		}
+	}
+}
+
+// sortedCounts returns the keys of counts, most frequent first
+func sortedCounts(counts map[string]int) []string {
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (ls *libraryStats) print() {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "files\t%d\n", ls.Files)
+	fmt.Fprintf(tw, "annotated\t%d\n", ls.Annotated)
+	if ls.Errors > 0 {
+		fmt.Fprintf(tw, "errors\t%d\n", ls.Errors)
+	}
+	fmt.Fprintf(tw, "annotations\t%d\n", ls.Annotations)
+	for _, subtype := range sortedCounts(ls.BySubtype) {
+		fmt.Fprintf(tw, "  %s\t%d\n", subtype, ls.BySubtype[subtype])
+	}
+	for _, color := range sortedCounts(ls.ByColor) {
+		fmt.Fprintf(tw, "  %s\t%d\n", color, ls.ByColor[color])
+	}
+	tw.Flush()
+}
+
+var statsRoots []string
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "summarize the annotations of pdf files [json]",
+	Long: `
+	ghligh stats foo.pdf bar.pdf ... [--root dir] [--json] [-i]
+
+	counts the pdf files, how many of them have annotations and the
+	annotations, by subtype and by color, without exporting anything
+
+	--root will also count every pdf found recursively inside dir, like
+	ghligh export does (repeatable, --follow-symlinks, --include and
+	--exclude work the same)
+
+	if --json is set the output will be in json format, -i will indent it
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && len(statsRoots) == 0 {
+			cmd.Help()
+			return
+		}
+
+		useJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		files := args
+		if len(statsRoots) > 0 {
+			pdfs, err := scanRoots(context.Background(), statsRoots, cliScanOptions())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			files = append(files, pdfs...)
+		}
+
+		// stats are as fast as a cached export, without the cache they
+		// still work
+		cache, _ := openExportCache()
+
+		stats := newLibraryStats()
+		stats.Files = len(files)
+
+		e := &exporter{
+			concurrency: runtime.NumCPU(),
+			hashMode:    document.HashText,
+			cache:       cache,
+			onError: func(path string, err error) {
+				fmt.Fprintf(os.Stderr, "error loading %s: %v\n", path, err)
+			},
+		}
+		failed := len(files)
+		for doc := range e.stream(files) {
+			stats.add(doc)
+			failed--
+		}
+		stats.Errors = failed
+
+		if !useJSON {
+			stats.print()
+			return
+		}
+
+		var jsonBytes []byte
+		if indent {
+			jsonBytes, err = json.MarshalIndent(stats, "", "	")
+		} else {
+			jsonBytes, err = json.Marshal(stats)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(jsonBytes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().BoolP("json", "j", false, "print the stats as json")
+	statsCmd.Flags().BoolP("indent", "i", false, "indent the json output")
+	statsCmd.Flags().StringArrayVarP(&statsRoots, "root", "r", []string{}, "also count every pdf found recursively in this directory (repeatable)")
+	statsCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories of --root")
+	statsCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "only consider files of --root matching this glob pattern (repeatable)")
+	statsCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "skip paths of --root matching this glob pattern (repeatable)")
+
+	statsCmd.ValidArgsFunction = completePDFs
+	registerFileCompletion(statsCmd, "root", "")
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// fileTimeoutError is the error of a file that took longer than the per
+// file timeout to process, as opposed to one that couldn't be read
+type fileTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *fileTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+// withFileTimeout runs fn with a context done after timeout, a timeout of
+// 0 waits for fn however long it takes. The poppler calls can't be
+// interrupted, so on timeout fn is left running in the background and its
+// result thrown away: fn must check ctx before doing anything that can't be
+// undone, like saving a file.
+func withFileTimeout[T any](timeout time.Duration, fn func(ctx context.Context) T) (T, error) {
+	if timeout <= 0 {
+		return fn(context.Background()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan T, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case res := <-done:
+		return res, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, &fileTimeoutError{timeout}
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isMultipart reports whether r is a multipart/form-data upload
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// an uploaded pdf, path is where it was written and name the file name
+// sent by the client
+type uploadedPDF struct {
+	path string
+	name string
+}
+
+// readUpload writes the pdfs of a multipart request to dir and returns
+// them with the export json. The json is the part named "export" or any
+// .json file, every other file is expected to be a pdf.
+func readUpload(r *http.Request, dir string) ([]byte, []uploadedPDF, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var exportData []byte
+	var pdfs []uploadedPDF
+	names := make(map[string]bool)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		name := filepath.Base(part.FileName())
+		switch {
+		case part.FormName() == "export" || strings.EqualFold(filepath.Ext(name), ".json"):
+			exportData, err = io.ReadAll(part)
+		case part.FileName() == "":
+			// a plain form field, nothing to do with it
+		case !strings.EqualFold(filepath.Ext(name), ".pdf"):
+			err = fmt.Errorf("%s: only pdf and json files can be uploaded", name)
+		default:
+			// the name is kept, fuzzy matching uses it, but two uploads
+			// can have the same one
+			if names[name] {
+				name = strconv.Itoa(len(pdfs)) + "-" + name
+			}
+			names[name] = true
+
+			pdf := uploadedPDF{name: name, path: filepath.Join(dir, strconv.Itoa(len(pdfs)), name)}
+			err = writeUploaded(pdf.path, part)
+			pdfs = append(pdfs, pdf)
+		}
+		part.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if exportData == nil {
+		return nil, nil, fmt.Errorf("no export json in the upload, send it as the \"export\" part")
+	}
+	if len(pdfs) == 0 {
+		return nil, nil, fmt.Errorf("no pdf in the upload")
+	}
+	return exportData, pdfs, nil
+}
+
+func writeUploaded(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// uploadImport imports the export json of a multipart request into the
+// pdfs uploaded with it, in a temporary directory, and sends them all back
+// in a zip with the import summary as summary.json. With asPDF the single
+// uploaded pdf is sent back as is, see sendImportedPDF.
+func (s *server) uploadImport(w http.ResponseWriter, r *http.Request, im *importer, asPDF bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadBytes)
+
+	dir, err := os.MkdirTemp("", "ghligh-upload-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	exportData, pdfs, err := readUpload(r, dir)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("upload larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if asPDF && len(pdfs) != 1 {
+		http.Error(w, fmt.Sprintf("?pdf=true needs a single pdf, %d were uploaded", len(pdfs)), http.StatusBadRequest)
+		return
+	}
+
+	importedDocs, err := parseExport(exportData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ia := newImportedAnnots()
+	ia.load(importedDocs)
+
+	paths := make([]string, len(pdfs))
+	names := make(map[string]string)
+	for i, pdf := range pdfs {
+		paths[i] = pdf.path
+		names[pdf.path] = pdf.name
+	}
+
+	// the uploaded files are thrown away anyway, no backups and nothing
+	// worth caching
+	im.backup = false
+	im.docs = nil
+	im.targeted = len(pdfs) == 1
+	summary := im.importAll(paths, ia)
+	renameSummary(&summary, names)
+	s.logImport(summary)
+	s.metrics.importDone(summary, im.remove)
+
+	if asPDF {
+		s.sendImportedPDF(w, summary, pdfs[0].path, pdfs[0].name)
+		return
+	}
+
+	// with nothing saved there is nothing to send back but the summary
+	if !im.save {
+		writeJSON(w, importStatus(summary), summary)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="ghligh-import.zip"`)
+	w.WriteHeader(importStatus(summary))
+	if err := writeUploadZip(w, summary, pdfs); err != nil {
+		// the status is already sent, all that can be done is log it
+		s.logger.Warn("could not send the imported pdfs", "error", err)
+	}
+}
+
+// renameSummary replaces the temporary paths of the files in summary by
+// names, the ones the client knows them by, so that the paths of the
+// server don't leak in the response and the logs
+func renameSummary(summary *importSummary, names map[string]string) {
+	// errors and reasons can name the other files too (see
+	// applyDuplicatePolicy)
+	var pairs []string
+	for path, name := range names {
+		pairs = append(pairs, path, name)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	for i := range summary.Files {
+		res := &summary.Files[i]
+		res.File = names[res.File]
+		res.Error = replacer.Replace(res.Error)
+		res.Reason = replacer.Replace(res.Reason)
+		res.Warning = replacer.Replace(res.Warning)
+	}
+	sort.Slice(summary.Files, func(i, j int) bool {
+		return summary.Files[i].File < summary.Files[j].File
+	})
+
+	for hash, files := range summary.Duplicates {
+		renamed := make([]string, len(files))
+		for i, file := range files {
+			renamed[i] = names[file]
+		}
+		sort.Strings(renamed)
+		summary.Duplicates[hash] = renamed
+	}
+}
+
+// importCopy imports into a temporary copy of the pdf at path, named name
+// for the client, and sends the copy back with sendImportedPDF, path
+// itself is never modified
+func (s *server) importCopy(w http.ResponseWriter, im *importer, ia *importedAnnots, path string, name string) {
+	dir, err := os.MkdirTemp("", "ghligh-import-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the base name is kept, fuzzy matching uses it
+	cp := filepath.Join(dir, filepath.Base(path))
+	err = writeUploaded(cp, f)
+	f.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// like for uploads the copy is thrown away once sent
+	im.backup = false
+	im.docs = nil
+	im.targeted = true
+	summary := im.importAll([]string{cp}, ia)
+	renameSummary(&summary, map[string]string{cp: name})
+	s.logImport(summary)
+	s.metrics.importDone(summary, im.remove)
+
+	s.sendImportedPDF(w, summary, cp, filepath.Base(path))
+}
+
+// sendImportedPDF sends the pdf at path, the only file of summary, as
+// application/pdf with the number of imported annotations in the
+// X-Ghligh-Imported header (X-Ghligh-Removed for /remove). If the import
+// failed the summary is sent instead.
+func (s *server) sendImportedPDF(w http.ResponseWriter, summary importSummary, path string, name string) {
+	if len(summary.Files) != 1 || summary.Errors > 0 {
+		writeJSON(w, importStatus(summary), summary)
+		return
+	}
+	res := summary.Files[0]
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("X-Ghligh-Imported", strconv.Itoa(res.Imported))
+	if res.Removed > 0 {
+		w.Header().Set("X-Ghligh-Removed", strconv.Itoa(res.Removed))
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		// the status is already sent, all that can be done is log it
+		s.logger.Warn("could not send the imported pdf", "error", err)
+	}
+}
+
+func writeUploadZip(w io.Writer, summary importSummary, pdfs []uploadedPDF) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("summary.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(summary); err != nil {
+		return err
+	}
+
+	for _, pdf := range pdfs {
+		f, err := zw.Create(pdf.name)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(pdf.path)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameSummary(t *testing.T) {
+	names := map[string]string{
+		"/tmp/ghligh-upload-1/0/a.pdf": "a.pdf",
+		"/tmp/ghligh-upload-1/1/b.pdf": "copy of a.pdf",
+	}
+	summary := importSummary{
+		Files: []importFileResult{
+			{File: "/tmp/ghligh-upload-1/0/a.pdf", Error: "same document as /tmp/ghligh-upload-1/1/b.pdf, not importing into any of them"},
+			{File: "/tmp/ghligh-upload-1/1/b.pdf", Error: "same document as /tmp/ghligh-upload-1/0/a.pdf, not importing into any of them"},
+		},
+		Duplicates: map[string][]string{
+			"h": {"/tmp/ghligh-upload-1/0/a.pdf", "/tmp/ghligh-upload-1/1/b.pdf"},
+		},
+	}
+
+	renameSummary(&summary, names)
+
+	want := importSummary{
+		Files: []importFileResult{
+			{File: "a.pdf", Error: "same document as copy of a.pdf, not importing into any of them"},
+			{File: "copy of a.pdf", Error: "same document as a.pdf, not importing into any of them"},
+		},
+		Duplicates: map[string][]string{
+			"h": {"a.pdf", "copy of a.pdf"},
+		},
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("renameSummary = %+v, want %+v", summary, want)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// verifyData prints the problems of the export in data, read from name,
+// and returns how many were found
+func verifyData(name string, data []byte) int {
+	docs, err := parseExport(data)
+	if err != nil {
+		fmt.Printf("%s: %v\n", name, err)
+		return 1
+	}
+
+	problems := 0
+	for i := range docs {
+		doc := &docs[i]
+		label := doc.Path
+		if label == "" {
+			label = fmt.Sprintf("document %d", i+1)
+		}
+		for _, problem := range doc.Verify() {
+			fmt.Printf("%s: %s: %s\n", name, label, problem)
+			problems++
+		}
+	}
+	return problems
+}
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "check that export files are valid",
+	Long: `
+	ghligh verify fnord.json kadio.json ... [-0]
+
+	checks the export files given as arguments (or read from stdin with -0)
+	without opening any pdf: the json must be an export this ghligh
+	understands, every document a well formed hash, every annotation a
+	supported subtype, quad points that are not empty and lie inside of its
+	rectangle and an id matching the annotation and not used twice
+
+	every problem is printed on its own line, the exit status is 1 if there
+	was any so it can be used in scripts
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stdin, err := cmd.Flags().GetBool("stdin")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if len(args) == 0 && !stdin {
+			cmd.Help()
+			return
+		}
+
+		problems := 0
+		for _, file := range args {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				problems++
+				continue
+			}
+			problems += verifyData(file, data)
+		}
+
+		if stdin {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			problems += verifyData("stdin", data)
+		}
+
+		if problems > 0 {
+			fmt.Fprintf(os.Stderr, "%d problems found\n", problems)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().BoolP("stdin", "0", false, "read the export from stdin")
+
+	verifyCmd.ValidArgsFunction = completeJSON
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+	"github.com/spf13/cobra"
+)
+
+// set at build time with
+//
+//	go build -ldflags "-X github.com/prepuzio/ghligh/cmd.version=v1.0.0
+//		-X github.com/prepuzio/ghligh/cmd.commit=$(git rev-parse HEAD)
+//		-X github.com/prepuzio/ghligh/cmd.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo returns commit and date, falling back to what the go tool
+// recorded when they were not set with -ldflags
+func buildInfo() (string, string) {
+	c, d := commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && c == "":
+				c = setting.Value
+			case setting.Key == "vcs.time" && d == "":
+				d = setting.Value
+			}
+		}
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return c, d
+}
+
+func versionString() string {
+	c, d := buildInfo()
+	return fmt.Sprintf("%s (commit %s, built %s, %s, poppler %s)", version, c, d, runtime.Version(), poppler.Version())
+}
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "show the ghligh version and build info",
+	Long: `
+	ghligh version
+
+	prints the ghligh version, the git commit and date it was built from,
+	the go version and the poppler version, same as ghligh --version
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("ghligh %s\n", versionString())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.Version = versionString()
+}
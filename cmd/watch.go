@@ -0,0 +1,619 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prepuzio/ghligh/document"
+	"github.com/spf13/cobra"
+)
+
+var watchRoots []string
+
+// fileState is what tells watch that a file changed
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshot returns the state of every pdf under roots, pdfs inside
+// archives change with their archive
+func snapshot(ctx context.Context, roots []string, opts scanOptions) ([]string, map[string]fileState, error) {
+	pdfs, err := scanRoots(ctx, roots, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	states := make(map[string]fileState, len(pdfs))
+	for _, path := range pdfs {
+		statPath := path
+		if archive, _, ok := splitArchivePath(path); ok {
+			statPath = archive
+		}
+		info, err := os.Stat(statPath)
+		if err != nil {
+			// gone since the scan, the next one won't find it
+			continue
+		}
+		states[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return pdfs, states, nil
+}
+
+func sameStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// dirWatcher watches every directory under the roots, fsnotify only
+// reports the changes of the watched directory itself and not of the ones
+// below it
+type dirWatcher struct {
+	*fsnotify.Watcher
+	follow bool
+	// watched directories and their path with the symlinks resolved, the
+	// same directory reached through symlinks is watched once
+	dirs     map[string]string
+	resolved map[string]bool
+}
+
+func newDirWatcher(follow bool) (*dirWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &dirWatcher{
+		Watcher:  w,
+		follow:   follow,
+		dirs:     make(map[string]string),
+		resolved: make(map[string]bool),
+	}, nil
+}
+
+// watchRoot watches the directories under root, or the directory of root
+// when it is a file: saves replace the file, and its watch with it
+func (dw *dirWatcher) watchRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return dw.Add(filepath.Dir(root))
+	}
+	return dw.add(root)
+}
+
+// add watches dir and the directories below it, descending into symlinked
+// ones with dw.follow like the scan does
+func (dw *dirWatcher) add(dir string) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil || dw.resolved[resolved] {
+		// gone since it was seen, or already watched
+		return nil
+	}
+	if err := dw.Add(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	dw.dirs[dir] = resolved
+	dw.resolved[resolved] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			if !dw.follow || entry.Type()&fs.ModeSymlink == 0 {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || !info.IsDir() {
+				continue
+			}
+		}
+		if err := dw.add(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forget stops watching dir and the directories below it, called when dir
+// was removed or moved away
+func (dw *dirWatcher) forget(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for path, resolved := range dw.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			// removed directories lose their watch by themselves
+			dw.Remove(path)
+			delete(dw.dirs, path)
+			delete(dw.resolved, resolved)
+		}
+	}
+}
+
+// watchState is what the last export was made of
+type watchState struct {
+	pdfs []string
+	// the documents exported without error, by path
+	docs map[string]document.GhlighDoc
+}
+
+// needsScan reports whether the files in changed may have added or removed
+// pdfs, instead of only modifying known ones. A nil changed stands for
+// changes that weren't reported, the roots must be scanned again.
+func (s *watchState) needsScan(changed map[string]bool, archives bool) bool {
+	if changed == nil {
+		return true
+	}
+
+	files := make(map[string]bool, len(s.pdfs))
+	for _, path := range s.pdfs {
+		if archive, _, ok := splitArchivePath(path); ok {
+			// the archive may hold other pdfs now
+			if changed[archive] {
+				return true
+			}
+			continue
+		}
+		files[path] = true
+	}
+
+	for path := range changed {
+		info, err := os.Stat(path)
+		switch {
+		case files[path]:
+			if err != nil || !info.Mode().IsRegular() {
+				return true
+			}
+		case isDocument(path), archives && filepath.Ext(path) == ".zip":
+			return true
+		case err == nil && info.IsDir():
+			// created or moved in
+			return true
+		case err != nil && s.hasUnder(path):
+			// a directory removed or moved away
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnder reports whether a known pdf is inside of the directory dir
+func (s *watchState) hasUnder(dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for _, path := range s.pdfs {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedPaths returns the paths in changed with their symlinks resolved,
+// a pdf reached through symlinked directories may be reported under
+// another path than the one found by the scan
+func resolvedPaths(changed map[string]bool) map[string]bool {
+	resolved := make(map[string]bool, len(changed))
+	for path := range changed {
+		if r, err := filepath.EvalSymlinks(path); err == nil {
+			resolved[r] = true
+		}
+	}
+	return resolved
+}
+
+// resolvedChanged reports whether file is one of the resolvedPaths
+func resolvedChanged(file string, resolved map[string]bool) bool {
+	if len(resolved) == 0 {
+		return false
+	}
+	r, err := filepath.EvalSymlinks(file)
+	return err == nil && resolved[r]
+}
+
+// update brings s up to date with the files in changed (nil when unknown,
+// see needsScan). The roots are only scanned again when pdfs may have
+// been added or removed, and only the new and changed pdfs are exported
+// again.
+func (s *watchState) update(ctx context.Context, e *exporter, roots []string, opts scanOptions, changed map[string]bool) error {
+	pdfs := s.pdfs
+	if s.needsScan(changed, opts.archives) {
+		var err error
+		pdfs, err = scanRoots(ctx, roots, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	known := make(map[string]bool, len(s.pdfs))
+	if changed != nil {
+		for _, path := range s.pdfs {
+			known[path] = true
+		}
+	}
+	var resolved map[string]bool
+	if opts.followSymlinks && changed != nil {
+		resolved = resolvedPaths(changed)
+	}
+
+	docs := make(map[string]document.GhlighDoc, len(pdfs))
+	var stale []string
+	for _, path := range pdfs {
+		file := path
+		if archive, _, ok := splitArchivePath(path); ok {
+			file = archive
+		}
+		if known[path] && !changed[file] && !resolvedChanged(file, resolved) {
+			if doc, ok := s.docs[path]; ok {
+				docs[path] = doc
+			}
+			continue
+		}
+		if known[path] {
+			// a save within the same second keeping the size would look
+			// unchanged to the cache
+			e.docs.forget(path)
+		}
+		stale = append(stale, path)
+	}
+	for _, doc := range e.exportAll(stale) {
+		docs[doc.Path] = doc
+	}
+
+	s.pdfs = pdfs
+	s.docs = docs
+	return nil
+}
+
+// documents returns the exported documents sorted by path, like
+// exporter.exportAll
+func (s *watchState) documents() []document.GhlighDoc {
+	docs := make([]document.GhlighDoc, 0, len(s.docs))
+	for _, doc := range s.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].Path < docs[j].Path
+	})
+	return docs
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".ghligh_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if cerr := tempFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "export again every time pdf files change",
+	Long: `
+	ghligh watch --root dir --out export.json [--debounce 1s] [--poll [--interval 2s]]
+		[--text] [--format json|markdown|obsidian|csv|tsv|readwise] [--hash-mode text|bytes] [-i]
+
+	exports every pdf found recursively inside --root (repeatable,
+	--follow-symlinks, --include, --exclude and --zip work like in ghligh
+	export) to
+	--out, then keeps running and exports again whenever a pdf is added,
+	removed or modified, until interrupted
+
+	the directories under --root are watched for changes reported by the
+	filesystem, a new export starts once nothing changed for --debounce so
+	that a burst of saves gives a single export. Only the changed pdfs are
+	exported again, the others are kept from the previous export.
+	--out is replaced atomically: readers see the old export or the new
+	one, never half of it
+
+	with --poll the pdfs are checked every --interval instead, for the
+	filesystems not reporting changes (network shares) or files out of
+	--root reached through symlinks
+
+	--text, --format, --hash-mode and -i work like in ghligh export
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, err := cmd.Flags().GetString("out")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if len(watchRoots) == 0 || out == "" {
+			cmd.Help()
+			return
+		}
+
+		interval, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		debounce, err := cmd.Flags().GetDuration("debounce")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		poll, err := cmd.Flags().GetBool("poll")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		if poll && interval <= 0 {
+			fmt.Fprintf(os.Stderr, "--interval must be positive\n")
+			os.Exit(1)
+		}
+
+		withText, err := cmd.Flags().GetBool("text")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		indent, err := cmd.Flags().GetBool("indent")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+		if !validExportFormat(format) {
+			fmt.Fprintf(os.Stderr, "unknown format %s, valid formats are %v\n", format, exportFormats)
+			os.Exit(1)
+		}
+		if format != "json" {
+			withText = true
+		}
+
+		hashModeFlag, err := cmd.Flags().GetString("hash-mode")
+		if err != nil {
+			cmd.Help()
+			return
+		}
+
+		hashMode, err := document.ParseHashMode(hashModeFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		cache, err := openExportCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "not using the cache: %v\n", err)
+		}
+
+		opts := cliScanOptions()
+		e := &exporter{
+			concurrency: runtime.NumCPU(),
+			cache:       cache,
+			withText:    withText,
+			hashMode:    hashMode,
+			onError: func(path string, err error) {
+				fmt.Fprintf(os.Stderr, "error loading %s: %v\n", path, err)
+			},
+		}
+
+		write := func(docs []document.GhlighDoc) {
+			var output bytes.Buffer
+			if err := writeExport(&output, format, docs, indent, 0); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			if err := writeFileAtomic(out, output.Bytes()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "%s: exported %d documents to %s\n",
+				time.Now().Format(time.TimeOnly), len(docs), out)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if poll {
+			pollChanges(ctx, e, opts, interval, debounce, write)
+			return
+		}
+
+		dw, err := newDirWatcher(opts.followSymlinks)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, try --poll\n", err)
+			os.Exit(1)
+		}
+		defer dw.Close()
+		// watched before the first scan, so that nothing changed during
+		// the first export is missed
+		for _, root := range watchRoots {
+			if err := dw.watchRoot(root); err != nil {
+				fmt.Fprintf(os.Stderr, "%v, try --poll\n", err)
+				os.Exit(1)
+			}
+		}
+
+		pdfs, err := scanRoots(ctx, watchRoots, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		// looked up when every pdf is exported again after the events
+		// overflowed, watchState.update keeps the unchanged ones otherwise
+		e.docs = newDocCache(2*len(pdfs) + 64)
+		state := &watchState{pdfs: pdfs, docs: make(map[string]document.GhlighDoc, len(pdfs))}
+		for _, doc := range e.exportAll(pdfs) {
+			state.docs[doc.Path] = doc
+		}
+		write(state.documents())
+
+		// files changed since the last export, nil after an overflow
+		// of the events: anything may have changed
+		changed := make(map[string]bool)
+		var quiet <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-dw.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := dw.add(event.Name); err != nil {
+							fmt.Fprintf(os.Stderr, "%v\n", err)
+						}
+					}
+				}
+				if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					dw.forget(event.Name)
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if changed != nil {
+					changed[event.Name] = true
+				}
+			case err, ok := <-dw.Errors:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				if !errors.Is(err, fsnotify.ErrEventOverflow) {
+					continue
+				}
+				changed = nil
+			case <-quiet:
+				if err := state.update(ctx, e, watchRoots, opts, changed); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					// tried again with the next change
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					quiet = nil
+					continue
+				}
+				write(state.documents())
+				changed = make(map[string]bool)
+				quiet = nil
+				continue
+			}
+			// a burst of saves gives a single export
+			quiet = time.After(debounce)
+		}
+	},
+}
+
+// pollChanges exports the pdfs under watchRoots then checks them every
+// interval, exporting them again once nothing changed for debounce
+func pollChanges(ctx context.Context, e *exporter, opts scanOptions, interval, debounce time.Duration, write func([]document.GhlighDoc)) {
+	pdfs, exported, err := snapshot(ctx, watchRoots, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// with room for the library to grow, every export after the
+	// first one only reads the changed files
+	e.docs = newDocCache(2*len(pdfs) + 64)
+	write(e.exportAll(pdfs))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// last seen state and when it was first seen
+	last := exported
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, states, err := snapshot(ctx, watchRoots, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+
+		if !sameStates(states, last) {
+			last = states
+			lastChange = time.Now()
+		}
+		if sameStates(last, exported) || time.Since(lastChange) < debounce {
+			continue
+		}
+
+		write(e.exportAll(current))
+		exported = last
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringArrayVarP(&watchRoots, "root", "r", []string{}, "watch every pdf found recursively in this directory (repeatable)")
+	watchCmd.Flags().StringP("out", "o", "", "file replaced with every new export")
+	watchCmd.Flags().Bool("poll", false, "check the pdfs every --interval instead of waiting for the filesystem to report changes")
+	watchCmd.Flags().Duration("interval", 2*time.Second, "with --poll, how often the pdfs are checked for changes")
+	watchCmd.Flags().Duration("debounce", time.Second, "how long nothing must change before exporting again")
+	watchCmd.Flags().Bool("text", false, "also export the highlighted text")
+	watchCmd.Flags().String("format", "json", "output format (json, markdown, obsidian, csv, tsv, readwise)")
+	watchCmd.Flags().String("hash-mode", string(document.HashText), "how documents are identified (text, bytes)")
+	watchCmd.Flags().BoolP("indent", "i", false, "indent the json data")
+	watchCmd.Flags().BoolVar(&scanArchives, "zip", false, "also export the pdfs inside .zip archives of --root")
+	watchCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories of --root")
+	watchCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "only consider files of --root matching this glob pattern (repeatable)")
+	watchCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "skip paths of --root matching this glob pattern (repeatable)")
+
+	registerCompletion(watchCmd, "format", exportFormats...)
+	registerCompletion(watchCmd, "hash-mode", hashModeNames()...)
+	registerFileCompletion(watchCmd, "out", "")
+	registerFileCompletion(watchCmd, "root", "")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchNeedsScan(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, root, "a.pdf")
+	touch(t, root, "sub/b.pdf")
+	touch(t, root, "notes.txt")
+	touch(t, root, "new/c.pdf")
+
+	state := &watchState{pdfs: []string{
+		filepath.Join(root, "a.pdf"),
+		filepath.Join(root, "sub", "b.pdf"),
+	}}
+	if err := os.RemoveAll(filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		changed []string
+		want    bool
+	}{
+		// saved again
+		{[]string{"a.pdf"}, false},
+		{[]string{"notes.txt"}, false},
+		// temporary files of a save, gone once it is done
+		{[]string{".ghligh_123.pdf", "a.pdf"}, false},
+		// a pdf added or removed
+		{[]string{"d.pdf"}, true},
+		{[]string{"sub/b.pdf"}, true},
+		// a directory moved in or away
+		{[]string{"new"}, true},
+		{[]string{"sub"}, true},
+		{[]string{"gone"}, false},
+	} {
+		changed := make(map[string]bool)
+		for _, rel := range test.changed {
+			changed[filepath.Join(root, rel)] = true
+		}
+		if got := state.needsScan(changed, false); got != test.want {
+			t.Errorf("needsScan(%q) = %v, want %v", test.changed, got, test.want)
+		}
+	}
+
+	if !state.needsScan(nil, false) {
+		t.Errorf("needsScan(nil) = false, want true")
+	}
+}
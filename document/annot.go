@@ -1,76 +1,193 @@
 package document
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
 	"github.com/prepuzio/ghligh/go-poppler"
 )
 
+// markup annotations handled by ghligh, named after their pdf /Subtype:
+// text markup on the page text and free text boxes
+var markupSubtypes = map[poppler.AnnotType]string{
+	poppler.AnnotHighlight: "Highlight",
+	poppler.AnnotUnderline: "Underline",
+	poppler.AnnotSquiggly:  "Squiggly",
+	poppler.AnnotStrikeOut: "StrikeOut",
+	poppler.AnnotFreeText:  "FreeText",
+}
+
+// color given to imported annotations that don't have one, poppler
+// colors are 16 bits per channel
+var defaultColor = poppler.Color{R: 0xffff, G: 0xffff, B: 0}
+
+func isMarkup(t poppler.AnnotType) bool {
+	_, ok := markupSubtypes[t]
+	return ok
+}
+
+// isTextMarkup reports whether annotations of type t mark the page text
+// under their quad points, free text annotations have their own text
+func isTextMarkup(t poppler.AnnotType) bool {
+	return isMarkup(t) && t != poppler.AnnotFreeText
+}
+
+// subtypeToType returns the annotation type named by subtype, exports made
+// before subtypes were recorded only contain highlights so "" is a highlight
+func subtypeToType(subtype string) (poppler.AnnotType, error) {
+	if subtype == "" {
+		return poppler.AnnotHighlight, nil
+	}
+	for t, name := range markupSubtypes {
+		if strings.EqualFold(name, subtype) {
+			return t, nil
+		}
+	}
+	return poppler.AnnotUnknown, fmt.Errorf("unsupported annotation subtype %q", subtype)
+}
+
+// ParseSubtype returns the name of the annotation subtype s (case doesn't
+// matter), or an error if ghligh doesn't handle it
+func ParseSubtype(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty annotation subtype, use one of %v", SubtypeNames())
+	}
+	t, err := subtypeToType(s)
+	if err != nil {
+		return "", err
+	}
+	return markupSubtypes[t], nil
+}
+
+// SubtypeNames returns the names of the annotation subtypes ghligh
+// handles, sorted
+func SubtypeNames() []string {
+	var names []string
+	for _, name := range markupSubtypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type AnnotJSON struct {
-	Type     poppler.AnnotType `json:"type,omitempty"`
-	Index    int               `json:"index,omitempty"`
-	Date     string            `json:"date,omitempty"`
-	Rect     poppler.Rectangle `json:"rect,omitempty"`
-	Color    poppler.Color     `json:"color,omitempty"`
-	Name     string            `json:"name,omitempty"`
-	Contents string            `json:"contents,omitempty"`
-	Flags    poppler.AnnotFlag `json:"flags,omitempty"`
-	Quads    []poppler.Quad    `json:"quads,omitempty"`
+	// ID is the same for the same annotation in every export, see AnnotID
+	ID      string            `json:"id,omitempty"`
+	Type    poppler.AnnotType `json:"type,omitempty"`
+	Subtype string            `json:"subtype,omitempty"`
+	Index   int               `json:"index,omitempty"`
+	Date    string            `json:"date,omitempty"`
+	Rect    poppler.Rectangle `json:"rect,omitempty"`
+	Color   *poppler.Color    `json:"color,omitempty"`
+	// Opacity goes from 0 to 1, it is only set for annotations that are
+	// not fully opaque
+	Opacity *float64 `json:"opacity,omitempty"`
+	// BorderWidth is the line width of underlines, squiggles and strike
+	// outs, in points, only set when it isn't the default of 1
+	BorderWidth *float64 `json:"border_width,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Contents    string   `json:"contents,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	// Created and Subject are only exported, poppler can't set them on
+	// new annotations
+	Created string            `json:"created,omitempty"`
+	Subject string            `json:"subject,omitempty"`
+	Flags   poppler.AnnotFlag `json:"flags,omitempty"`
+	Quads   []poppler.Quad    `json:"quads,omitempty"`
+	Popup   *AnnotPopup       `json:"popup,omitempty"`
+	// Font and FontSize (in points) are only set for free text
+	// annotations, whose text is in Contents
+	Font     string  `json:"font,omitempty"`
+	FontSize float64 `json:"font_size,omitempty"`
+	// Text is the page text covered by the annotation, it is only
+	// filled by GetAnnotsText
+	Text string `json:"text,omitempty"`
+}
+
+// AnnotPopup is the popup window showing the note of an annotation
+type AnnotPopup struct {
+	Rect poppler.Rectangle `json:"rect"`
+	Open bool              `json:"open,omitempty"`
 }
 
 func annotToJson(a poppler.Annot) AnnotJSON {
 	var aj AnnotJSON
 	aj.Type = a.Type()
+	aj.Subtype = markupSubtypes[aj.Type]
 	aj.Index = a.Index()
 	aj.Date = a.Date()
 	aj.Rect = a.Rect()
-	aj.Color = a.Color()
+	if a.HasColor() {
+		color := a.Color()
+		aj.Color = &color
+	}
+	if opacity := a.Opacity(); opacity < 1 {
+		aj.Opacity = &opacity
+	}
+	if width, ok := a.BorderWidth(); ok && width != 1 {
+		aj.BorderWidth = &width
+	}
 	aj.Name = a.Name()
 	aj.Contents = a.Contents()
+	aj.Author = a.Author()
+	aj.Created = a.Created()
+	aj.Subject = a.Subject()
 	aj.Flags = a.Flags()
 	aj.Quads = a.Quads()
+	if rect, open, ok := a.Popup(); ok {
+		aj.Popup = &AnnotPopup{Rect: rect, Open: open}
+	}
+	if font, size, ok := a.Font(); ok {
+		aj.Font = font
+		aj.FontSize = size
+	}
 
 	return aj
 }
 
-func (d *GhlighDoc) jsonToAnnot(aJson AnnotJSON) *poppler.Annot {
+func (d *GhlighDoc) jsonToAnnot(aJson AnnotJSON) (*poppler.Annot, error) {
+	t, err := subtypeToType(aJson.Subtype)
+	if err != nil {
+		return nil, err
+	}
 
-	annot, _ := d.doc.NewAnnot(poppler.AnnotHighlight, aJson.Rect, aJson.Quads)
+	annot, err := d.doc.NewAnnot(t, aJson.Rect, aJson.Quads)
+	if err != nil {
+		return nil, err
+	}
 
-	annot.SetColor(aJson.Color)
+	if aJson.Color != nil {
+		annot.SetColor(*aJson.Color)
+	} else {
+		annot.SetColor(defaultColor)
+	}
+	if aJson.Opacity != nil {
+		annot.SetOpacity(math.Max(0, math.Min(1, *aJson.Opacity)))
+	} else {
+		annot.SetOpacity(1)
+	}
+	if aJson.BorderWidth != nil {
+		if err := annot.SetBorderWidth(math.Max(0, *aJson.BorderWidth)); err != nil {
+			annot.Close()
+			return nil, err
+		}
+	}
 	annot.SetContents(aJson.Contents)
-	annot.SetFlags(aJson.Flags)
-
-	return &annot
-}
-
-func popplerAnnotsMatch(a *poppler.Annot, b *poppler.Annot) bool {
-	aRect := a.Rect()
-	bRect := b.Rect()
-
-	aQuads := a.Quads()
-	bQuads := b.Quads()
-
-	if aRect.X1 != bRect.X1 ||
-		aRect.Y1 != bRect.Y1 ||
-		aRect.X2 != bRect.X2 ||
-		aRect.Y2 != bRect.Y2 {
-		return false
+	if aJson.Author != "" {
+		annot.SetAuthor(aJson.Author)
 	}
-
-	if len(aQuads) != len(bQuads) {
-		return false
+	annot.SetFlags(aJson.Flags)
+	if aJson.Popup != nil {
+		annot.SetPopup(aJson.Popup.Rect, aJson.Popup.Open)
 	}
-
-	for i := range aQuads {
-		q1 := aQuads[i]
-		q2 := bQuads[i]
-
-		if q1.P1.X != q2.P1.X || q1.P1.Y != q2.P1.Y ||
-			q1.P2.X != q2.P2.X || q1.P2.Y != q2.P2.Y ||
-			q1.P3.X != q2.P3.X || q1.P3.Y != q2.P3.Y ||
-			q1.P4.X != q2.P4.X || q1.P4.Y != q2.P4.Y {
-			return false
+	if aJson.Font != "" || aJson.FontSize > 0 {
+		if err := annot.SetFont(aJson.Font, aJson.FontSize); err != nil {
+			annot.Close()
+			return nil, err
 		}
 	}
 
-	return true
+	return &annot, nil
 }
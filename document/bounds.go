@@ -0,0 +1,68 @@
+package document
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+func clamp(v float64, max float64) float64 {
+	return math.Min(math.Max(v, 0), max)
+}
+
+func clampPoint(p poppler.Point, width float64, height float64) poppler.Point {
+	return poppler.Point{X: clamp(p.X, width), Y: clamp(p.Y, height)}
+}
+
+// fitToPage returns a with its rectangle and quad points cut to a page of
+// the given size and whether anything had to be cut, ok is false when a is
+// entirely outside of the page
+func fitToPage(a AnnotJSON, width float64, height float64) (fitted AnnotJSON, clamped bool, ok bool) {
+	r := a.Rect
+	minX, maxX := math.Min(r.X1, r.X2), math.Max(r.X1, r.X2)
+	minY, maxY := math.Min(r.Y1, r.Y2), math.Max(r.Y1, r.Y2)
+	if maxX < 0 || maxY < 0 || minX > width || minY > height {
+		return a, false, false
+	}
+
+	fitted = a
+	fitted.Rect = poppler.Rectangle{
+		X1: clamp(r.X1, width),
+		Y1: clamp(r.Y1, height),
+		X2: clamp(r.X2, width),
+		Y2: clamp(r.Y2, height),
+	}
+
+	// a copy, the quads of a may be shared with other imports
+	fitted.Quads = make([]poppler.Quad, len(a.Quads))
+	for i, q := range a.Quads {
+		fitted.Quads[i] = poppler.Quad{
+			P1: clampPoint(q.P1, width, height),
+			P2: clampPoint(q.P2, width, height),
+			P3: clampPoint(q.P3, width, height),
+			P4: clampPoint(q.P4, width, height),
+		}
+		if fitted.Quads[i] != q {
+			clamped = true
+		}
+	}
+
+	return fitted, clamped || fitted.Rect != r, true
+}
+
+// SortReadingOrder sorts the annotations of every page top to bottom, then
+// left to right, so that exports of the same file are identical. Pdf
+// coordinates grow upwards, so the top of an annotation is its greatest y.
+func (am AnnotsMap) SortReadingOrder() {
+	for _, annots := range am {
+		sort.SliceStable(annots, func(i, j int) bool {
+			a, b := annots[i].Rect, annots[j].Rect
+			aTop, bTop := math.Max(a.Y1, a.Y2), math.Max(b.Y1, b.Y2)
+			if aTop != bTop {
+				return aTop > bTop
+			}
+			return math.Min(a.X1, a.X2) < math.Min(b.X1, b.X2)
+		})
+	}
+}
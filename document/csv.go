@@ -0,0 +1,99 @@
+package document
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+var csvHeader = []string{"file", "page", "subtype", "color", "author", "text", "note"}
+
+// ColorHex formats a poppler color (16 bits per channel) as #rrggbb, nil
+// (no color) as ""
+func ColorHex(c *poppler.Color) string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R>>8, c.G>>8, c.B>>8)
+}
+
+// csvRecords calls write with the header and then one row per annotation
+// of docs
+func csvRecords(docs []GhlighDoc, write func(record []string) error) error {
+	if err := write(csvHeader); err != nil {
+		return err
+	}
+
+	for i := range docs {
+		annots := docs[i].AnnotsBuffer
+		for _, page := range annots.sortedPages() {
+			for _, annot := range annots[page] {
+				record := []string{
+					docs[i].Path,
+					strconv.Itoa(page + 1),
+					annot.Subtype,
+					ColorHex(annot.Color),
+					annot.Author,
+					annot.Text,
+					annot.Contents,
+				}
+				if err := write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ExportCSV writes one row per annotation of docs, pages are numbered
+// from 1. The AnnotsBuffer of every document must already be filled, with
+// GetAnnotsText for the text column not to be empty.
+func ExportCSV(w io.Writer, docs []GhlighDoc) error {
+	return ExportCSVDelimiter(w, docs, ',')
+}
+
+// ExportCSVDelimiter is ExportCSV separating fields with delimiter, like
+// the ';' spreadsheets expect in locales using ',' as decimal separator.
+// Fields containing delimiter are quoted.
+func ExportCSVDelimiter(w io.Writer, docs []GhlighDoc, delimiter rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+
+	if err := csvRecords(docs, cw.Write); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// tsvField replaces the tabs and line breaks of s, that can't be in a tsv
+// field, with spaces
+var tsvField = strings.NewReplacer("\r\n", " ", "\t", " ", "\n", " ", "\r", " ")
+
+// ExportTSV writes the rows of ExportCSV as tab separated values, without
+// any quoting: tabs and line breaks in the text are turned into spaces
+func ExportTSV(w io.Writer, docs []GhlighDoc) error {
+	bw := bufio.NewWriter(w)
+
+	err := csvRecords(docs, func(record []string) error {
+		for i, field := range record {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			bw.WriteString(tsvField.Replace(field))
+		}
+		_, err := bw.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,121 @@
+package document
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// DefaultDedupThreshold is the overlap above which Dedup merges two
+// annotations
+const DefaultDedupThreshold = 0.9
+
+// rectArea returns the area of a bounding box from quadBounds
+func rectArea(r poppler.Rectangle) float64 {
+	return (r.X2 - r.X1) * (r.Y2 - r.Y1)
+}
+
+// quadsArea returns the area covered by the quads of a, quads of the same
+// annotation are lines of text and don't overlap
+func quadsArea(a AnnotJSON) float64 {
+	area := 0.0
+	for _, q := range a.Quads {
+		area += rectArea(quadBounds(q))
+	}
+	return area
+}
+
+// quadsOverlap returns the share of the smaller of a and b covered by the
+// other one, from 0 (apart) to 1 (one inside the other)
+func quadsOverlap(a, b AnnotJSON) float64 {
+	smaller := math.Min(quadsArea(a), quadsArea(b))
+	if smaller <= 0 {
+		return 0
+	}
+
+	shared := 0.0
+	for _, qa := range a.Quads {
+		ra := quadBounds(qa)
+		for _, qb := range b.Quads {
+			rb := quadBounds(qb)
+			w := math.Min(ra.X2, rb.X2) - math.Max(ra.X1, rb.X1)
+			h := math.Min(ra.Y2, rb.Y2) - math.Max(ra.Y1, rb.Y1)
+			if w > 0 && h > 0 {
+				shared += w * h
+			}
+		}
+	}
+	return math.Min(shared/smaller, 1)
+}
+
+// sameMarkup reports whether a and b could be copies of each other: same
+// subtype and color, and no two different notes
+func sameMarkup(a, b AnnotJSON) bool {
+	if a.Subtype != b.Subtype || ColorHex(a.Color) != ColorHex(b.Color) {
+		return false
+	}
+	return a.Contents == "" || b.Contents == "" || a.Contents == b.Contents
+}
+
+// Dedup merges the text markup annotations of the same page, subtype and
+// color whose quad points overlap by more than threshold (see
+// quadsOverlap), keeping the one covering the largest area. A note of a
+// merged annotation is moved to the kept one if that has none, annotations
+// with different notes are never merged. It returns how many annotations
+// were removed.
+func (d *GhlighDoc) Dedup(threshold float64) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	merged := 0
+
+	for i := 0; i < d.doc.GetNPages(); i++ {
+		page := d.doc.GetPage(i)
+
+		type candidate struct {
+			annot *poppler.Annot
+			json  AnnotJSON
+			area  float64
+		}
+		var candidates []candidate
+		for _, annot := range page.GetAnnots() {
+			if !isTextMarkup(annot.Type()) {
+				continue
+			}
+			aj := annotToJson(*annot)
+			if len(aj.Quads) == 0 {
+				continue
+			}
+			candidates = append(candidates, candidate{annot, aj, quadsArea(aj)})
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].area > candidates[b].area
+		})
+
+		removed := make([]bool, len(candidates))
+		for k := range candidates {
+			if removed[k] {
+				continue
+			}
+			kept := &candidates[k]
+			for j := k + 1; j < len(candidates); j++ {
+				other := candidates[j]
+				if removed[j] || !sameMarkup(kept.json, other.json) ||
+					quadsOverlap(kept.json, other.json) < threshold {
+					continue
+				}
+				if kept.json.Contents == "" && other.json.Contents != "" {
+					kept.annot.SetContents(other.json.Contents)
+					kept.json.Contents = other.json.Contents
+				}
+				page.RemoveAnnot(*other.annot)
+				removed[j] = true
+				merged++
+			}
+		}
+
+		page.Close()
+	}
+
+	return merged
+}
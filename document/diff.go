@@ -0,0 +1,76 @@
+package document
+
+// PageDiff tells how the annotations of a page differ from an import
+type PageDiff struct {
+	// Added annotations are in the import but not in the document
+	Added int `json:"added"`
+	// Removed annotations are in the document but not in the import
+	Removed int `json:"removed"`
+}
+
+// AnnotsMatch reports whether a and b are the same annotation: same
+// subtype, rectangle and quad points
+func AnnotsMatch(a AnnotJSON, b AnnotJSON) bool {
+	aType, aErr := subtypeToType(a.Subtype)
+	bType, bErr := subtypeToType(b.Subtype)
+	if aErr != nil || bErr != nil || aType != bType {
+		return false
+	}
+
+	if a.Rect != b.Rect || len(a.Quads) != len(b.Quads) {
+		return false
+	}
+
+	for i := range a.Quads {
+		if a.Quads[i] != b.Quads[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// countMissing returns how many annotations of from have no match in in
+func countMissing(from []AnnotJSON, in []AnnotJSON) int {
+	missing := 0
+	for _, a := range from {
+		found := false
+		for _, b := range in {
+			if AnnotsMatch(a, b) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing++
+		}
+	}
+	return missing
+}
+
+// Diff compares the annotations of the document with am, only pages that
+// differ are returned
+func (d *GhlighDoc) Diff(am AnnotsMap) map[int]PageDiff {
+	current := d.GetAnnotsBuffer()
+
+	pages := make(map[int]bool)
+	for page := range am {
+		pages[page] = true
+	}
+	for page := range current {
+		pages[page] = true
+	}
+
+	diff := make(map[int]PageDiff)
+	for page := range pages {
+		pd := PageDiff{
+			Added:   countMissing(am[page], current[page]),
+			Removed: countMissing(current[page], am[page]),
+		}
+		if pd.Added > 0 || pd.Removed > 0 {
+			diff[page] = pd
+		}
+	}
+
+	return diff
+}
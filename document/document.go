@@ -3,7 +3,10 @@ package document
 import (
 	"github.com/prepuzio/ghligh/go-poppler"
 
+	"errors"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 
 	"strings"
@@ -13,31 +16,102 @@ import (
 
 const ghlighFilter = "ghligh-Y2lhbm5v:"
 
+// TempPrefix starts the names of the files pdfs are written to before
+// being renamed in place, they are not documents of their own
+const TempPrefix = ".ghligh_"
+
 // This is different from poppler's annot_mapping
 // it is the list of annotations mapped to the page index
 type AnnotsMap map[int][]AnnotJSON
 
+// FormatVersion is the version of the export format written by this
+// ghligh, bump it when the meaning of existing fields changes
+const FormatVersion = 1
+
 type GhlighDoc struct {
 	doc *poppler.Document
-	mu  sync.Mutex
-
-	Path         string    `json:"file"`
-	HashBuffer   string    `json:"hash"`
+	// mu guards doc, it is a pointer so that copies of a GhlighDoc (e.g.
+	// in []GhlighDoc) share it along with doc
+	mu *sync.Mutex
+	// content of documents made with Load, they can't be saved
+	data []byte
+	// see SetOCR
+	ocr OCRFunc
+
+	// Version of the export format, exports made before it was recorded
+	// don't have it and are version 1
+	Version int `json:"version,omitempty"`
+
+	Path       string   `json:"file"`
+	HashBuffer string   `json:"hash"`
+	HashMode   HashMode `json:"hash_mode,omitempty"`
+	// Title, Author and PageCount are filled by LoadInfo
+	Title     string `json:"title,omitempty"`
+	Author    string `json:"author,omitempty"`
+	PageCount int    `json:"page_count,omitempty"`
+	// Archive is the zip file the document was read from, if any
+	Archive      string    `json:"archive,omitempty"`
 	AnnotsBuffer AnnotsMap `json:"highlights,omitempty"`
+	// PageSizes of the annotated pages, filled with AnnotsBuffer and
+	// ignored on import
+	PageSizes map[int]PageSize `json:"pages,omitempty"`
+	// PageErrors are the pages whose annotations couldn't be read, the
+	// others are still in AnnotsBuffer
+	PageErrors []PageError `json:"page_errors,omitempty"`
+}
+
+// PageError tells why the annotations of a page (an index, like the keys
+// of AnnotsMap) couldn't be read
+type PageError struct {
+	Page  int    `json:"page"`
+	Error string `json:"error"`
+}
+
+// PageSize is the size of a page in pdf points as shown (rotation already
+// applied), annotation coordinates are relative to it
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
 }
 
 type HighlightedText struct {
-	Page     int    `json:"page"`
-	Text     string `json:"text"`
-	Contents string `json:"contents,omitempty"`
+	Page     int            `json:"page"`
+	Text     string         `json:"text"`
+	Contents string         `json:"contents,omitempty"`
+	Color    *poppler.Color `json:"color,omitempty"`
+}
+
+// ErrEncrypted is returned by Open for password protected pdfs, see
+// OpenWithPassword
+var ErrEncrypted = poppler.ErrEncrypted
+
+// CheckVersion returns an error if one of docs was exported with a format
+// version newer than FormatVersion
+func CheckVersion(docs []GhlighDoc) error {
+	for i := range docs {
+		if docs[i].Version > FormatVersion {
+			return fmt.Errorf("%s was exported with format version %d, this ghligh only understands up to version %d",
+				docs[i].Path, docs[i].Version, FormatVersion)
+		}
+		if docs[i].Version < 0 {
+			return fmt.Errorf("%s has invalid format version %d", docs[i].Path, docs[i].Version)
+		}
+	}
+	return nil
 }
 
 func Open(filename string) (*GhlighDoc, error) {
+	return OpenWithPassword(filename, "")
+}
+
+// OpenWithPassword is like Open for encrypted pdfs, password is ignored by
+// pdfs that aren't encrypted
+func OpenWithPassword(filename string, password string) (*GhlighDoc, error) {
 	var err error
 
-	g := &GhlighDoc{}
+	g := &GhlighDoc{mu: &sync.Mutex{}}
 
-	g.doc, err = poppler.Open(filename)
+	g.doc, err = poppler.OpenWithPassword(filename, password)
 	if err != nil {
 		fmt.Errorf("%s: error opening pdf %v", os.Args[0], err)
 		return nil, err
@@ -48,18 +122,75 @@ func Open(filename string) (*GhlighDoc, error) {
 	return g, nil
 }
 
+// ErrReadOnly is returned by Save for documents made with Load
+var ErrReadOnly = errors.New("document was loaded from memory and can't be saved")
+
+// Load reads a pdf from data (e.g. extracted from an archive), name is
+// used as its Path
+func Load(data []byte, name string) (*GhlighDoc, error) {
+	return LoadWithPassword(data, name, "")
+}
+
+// LoadWithPassword is like Load for encrypted pdfs, password is ignored by
+// pdfs that aren't encrypted
+func LoadWithPassword(data []byte, name string, password string) (*GhlighDoc, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s: empty pdf", name)
+	}
+
+	var err error
+	g := &GhlighDoc{mu: &sync.Mutex{}, data: data, Path: name}
+
+	g.doc, err = poppler.LoadWithPassword(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
 func (d *GhlighDoc) Close() {
 	d.AnnotsBuffer = nil
+	d.PageSizes = nil
 	d.HashBuffer = ""
 	if d.doc != nil {
 		d.doc.Close()
 	}
 }
 
+// Exported returns a copy of the exported fields of d without the pdf, it
+// can be kept and used after d is closed
+func (d *GhlighDoc) Exported() GhlighDoc {
+	e := *d
+	e.doc = nil
+	e.mu = nil
+	e.data = nil
+	e.ocr = nil
+	return e
+}
+
 func (d *GhlighDoc) Info() poppler.DocumentInfo {
 	return d.doc.Info()
 }
 
+// LoadInfo fills Title, Author and PageCount from the pdf metadata, the
+// title falls back to the file name for documents without one
+func (d *GhlighDoc) LoadInfo() {
+	info := d.doc.Info()
+
+	d.Title = strings.TrimSpace(info.Title)
+	if d.Title == "" {
+		d.Title = FileTitle(d.Path)
+	}
+	d.Author = strings.TrimSpace(info.Author)
+	d.PageCount = d.doc.GetNPages()
+}
+
+// FileTitle returns the name of a file without directory and extension,
+// the title of documents without one in their metadata
+func FileTitle(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
 func (d *GhlighDoc) tagExists(text string) bool {
 	for _, tag := range d.GetTags() {
 		if tag == text {
@@ -105,29 +236,89 @@ func (d *GhlighDoc) RemoveTags(tags []string) int {
 	return removedTags
 }
 
-func (d *GhlighDoc) Import(annotsMap AnnotsMap) (int, error) {
+// ImportOptions changes how Import adds annotations
+type ImportOptions struct {
+	// Force adds every annotation, even if the page already has one
+	// with the same id (see AnnotID)
+	Force bool
+	// Strict fails the import when an annotation is not entirely inside
+	// its page instead of cutting it to the page bounds
+	Strict bool
+}
+
+// ImportResult counts what Import did
+type ImportResult struct {
+	Imported int
+	// Clamped annotations were partly outside of their page and were cut
+	// to its bounds
+	Clamped int
+	// Dropped annotations were entirely outside of their page (or on a
+	// page the document doesn't have) and were not imported
+	Dropped int
+}
+
+// Import adds the annotations of annotsMap to the document, annotations
+// already in the page are skipped unless opts.Force is set. Annotations
+// are fitted to the bounds of their page, see ImportOptions.Strict.
+func (d *GhlighDoc) Import(annotsMap AnnotsMap, opts ImportOptions) (ImportResult, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	annots_count := 0
+	var res ImportResult
 
 	var err error
 	d.AnnotsBuffer = annotsMap
 
+	nPages := d.doc.GetNPages()
 	for key := range d.AnnotsBuffer {
+		if key < 0 || key >= nPages {
+			if opts.Strict {
+				err = fmt.Errorf("page %d out of range, document has %d pages", key+1, nPages)
+				break
+			}
+			res.Dropped += len(d.AnnotsBuffer[key])
+			continue
+		}
+
 		page := d.doc.GetPage(key)
+		width, height := page.Size()
+		ids := pageAnnotIDs(page, key)
 		for _, annot := range d.AnnotsBuffer[key] {
-			a := d.jsonToAnnot(annot)
-			if !isInPage(a, page) {
-				annots_count += 1
-				page.AddAnnot(*a)
+			fitted, clamped, ok := fitToPage(annot, width, height)
+			if opts.Strict && (clamped || !ok) {
+				err = fmt.Errorf("annotation on page %d is outside of the page bounds", key+1)
+				break
+			}
+			if !ok {
+				res.Dropped += 1
+				continue
+			}
+
+			id := AnnotID(key, fitted)
+			if !opts.Force && ids[id] {
+				continue
+			}
+
+			var a *poppler.Annot
+			a, err = d.jsonToAnnot(fitted)
+			if err != nil {
+				break
+			}
+			res.Imported += 1
+			if clamped {
+				res.Clamped += 1
 			}
+			page.AddAnnot(*a)
+			ids[id] = true
 
 		}
 		page.Close()
+		if err != nil {
+			break
+		}
 	}
 
 	d.AnnotsBuffer = nil
-	return annots_count, err
+	return res, err
 }
 
 func integrityCheck(tizio *GhlighDoc, caio *GhlighDoc) {
@@ -152,31 +343,61 @@ func (d *GhlighDoc) GetPageText(i int) (string, error) {
 	return text, nil
 }
 
+// Save writes the document to a temporary file next to d.Path and renames
+// it over the original only once it is complete and synced to disk, so a
+// crash while saving never leaves a truncated pdf behind. os.Rename replaces
+// the destination on every platform, windows included (MoveFileEx with
+// MOVEFILE_REPLACE_EXISTING).
 func (d *GhlighDoc) Save() (bool, error) {
+	return d.SaveAs(d.Path)
+}
+
+// SaveAs is like Save but writes the document to path, leaving d.Path
+// untouched. The copy gets the permissions of the original.
+func (d *GhlighDoc) SaveAs(path string) (bool, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	tempFile, err := os.CreateTemp("", ".ghligh_*.pdf")
+
+	if d.data != nil {
+		return false, ErrReadOnly
+	}
+
+	info, err := os.Stat(d.Path)
 	if err != nil {
 		return false, err
 	}
-	defer os.Remove(tempFile.Name())
 
-	ok, err := d.doc.Save(tempFile.Name())
+	// same directory so the rename doesn't cross filesystems
+	tempFile, err := os.CreateTemp(filepath.Dir(path), TempPrefix+"*.pdf")
+	if err != nil {
+		return false, err
+	}
+	tempName := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempName)
+
+	ok, err := d.doc.Save(tempName)
 	if !ok {
 		return false, err
 	}
 
 	/* integrity check */
-	newDoc, err := Open(tempFile.Name())
+	newDoc, err := Open(tempName)
 	if err != nil {
 		return false, err
 	}
+	newHash := newDoc.HashDoc()
+	newDoc.Close()
 
-	if newDoc.HashDoc() != d.HashDoc() {
-		return false, fmt.Errorf("After saving document %s to %s its hash doesn't correspond the the old one", d.Path, tempFile.Name())
+	if newHash != d.HashDoc() {
+		return false, fmt.Errorf("After saving document %s to %s its hash doesn't correspond the the old one", d.Path, tempName)
+	}
+
+	if err := syncFile(tempName, info.Mode().Perm()); err != nil {
+		return false, err
 	}
 
-	err = os.Rename(tempFile.Name(), d.Path)
+	err = renameSynced(tempName, path)
 	if err != nil {
 		return false, err
 	}
@@ -184,6 +405,46 @@ func (d *GhlighDoc) Save() (bool, error) {
 	return true, nil
 }
 
+// syncFile flushes the file at path to disk and then sets its permissions,
+// in this order because perm may not let us open it for writing (a read
+// only pdf)
+func syncFile(path string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, perm)
+}
+
+// renameSynced renames from to to and flushes their directory so that the
+// rename survives a crash too
+func renameSynced(from string, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+
+	// windows can't open directories for this, the rename is already done
+	// either way so a directory that can't be flushed isn't an error
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(filepath.Dir(to))
+	if err != nil {
+		return nil
+	}
+	dir.Sync()
+	dir.Close()
+	return nil
+}
+
 func (d *GhlighDoc) Cat() []HighlightedText {
 	var highlights []HighlightedText
 
@@ -195,7 +456,12 @@ func (d *GhlighDoc) Cat() []HighlightedText {
 			if annot.Type() == poppler.AnnotHighlight {
 				annotText := page.AnnotText(*annot)
 
-				highlights = append(highlights, HighlightedText{Page: i, Text: annotText, Contents: annot.Contents()})
+				h := HighlightedText{Page: i, Text: annotText, Contents: annot.Contents()}
+				if annot.HasColor() {
+					color := annot.Color()
+					h.Color = &color
+				}
+				highlights = append(highlights, h)
 			}
 		}
 
@@ -226,29 +492,77 @@ func (d *GhlighDoc) HasHighlights() bool {
 	return false
 }
 
+// GetAnnotsBuffer returns the markup annotations of the document by page,
+// the size of every annotated page is stored in d.PageSizes
 func (d *GhlighDoc) GetAnnotsBuffer() AnnotsMap {
+	return d.getAnnots(false)
+}
+
+// GetAnnotsText is like GetAnnotsBuffer but also extracts the text
+// covered by each annotation, which is noticeably slower. See SetOCR for
+// scanned pages.
+func (d *GhlighDoc) GetAnnotsText() AnnotsMap {
+	return d.getAnnots(true)
+}
+
+func (d *GhlighDoc) getAnnots(withText bool) AnnotsMap {
 	annots_json_of_page := make(AnnotsMap)
+	sizes := make(map[int]PageSize)
+	var pageErrors []PageError
 
 	n := d.doc.GetNPages()
-	var annots_json []AnnotJSON
 	for i := 0; i < n; i++ {
-		annots_json = nil
-		page := d.doc.GetPage(i)
-
-		annots := page.GetAnnots()
-		for _, annot := range annots {
-			if annot.Type() == poppler.AnnotHighlight {
-				annot_json := annotToJson(*annot)
-				annots_json = append(annots_json, annot_json)
-			}
+		annots_json, size, err := d.pageAnnots(i, withText)
+		if err != nil {
+			// a damaged page doesn't cost the annotations of the others
+			pageErrors = append(pageErrors, PageError{Page: i, Error: err.Error()})
+			continue
 		}
 
-		page.Close()
-
 		if len(annots_json) > 0 {
 			annots_json_of_page[i] = annots_json
+			sizes[i] = size
 		}
 	}
 
+	d.PageSizes = sizes
+	d.PageErrors = pageErrors
 	return annots_json_of_page
 }
+
+// pageAnnots returns the markup annotations of page i and the size of the
+// page, turning a panic of the poppler bindings into an error
+func (d *GhlighDoc) pageAnnots(i int, withText bool) (annots_json []AnnotJSON, size PageSize, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	page, err := d.doc.OpenPage(i)
+	if err != nil {
+		return nil, PageSize{}, err
+	}
+	defer page.Close()
+
+	annots := page.GetAnnots()
+	// only looked at when needed, extracting the page text is slow
+	scanned := withText && d.ocr != nil && len(annots) > 0 && !hasTextLayer(page)
+	for _, annot := range annots {
+		if isMarkup(annot.Type()) {
+			annot_json := annotToJson(*annot)
+			annot_json.ID = AnnotID(i, annot_json)
+			switch {
+			case !withText || !isTextMarkup(annot.Type()):
+			case scanned:
+				annot_json.Text = d.ocrAnnotText(page, *annot)
+			default:
+				annot_json.Text = page.AnnotText(*annot)
+			}
+			annots_json = append(annots_json, annot_json)
+		}
+	}
+
+	width, height := page.Size()
+	return annots_json, PageSize{Width: width, Height: height}, nil
+}
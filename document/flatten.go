@@ -0,0 +1,98 @@
+package document
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Flatten writes to out a copy of d where every page is drawn with its
+// annotations baked into the page content, so highlights show on
+// printers and readers that ignore annotations. out can be d.Path to
+// replace the document. With keepAnnots the markup annotations are not
+// drawn but kept as annotations of the copy, they are removed from d (not
+// from its file) to do so.
+//
+// poppler can't write page content, the copy is drawn with cairo: unlike
+// Save the result is not checked to hash like the original, and links,
+// outlines, form fields, metadata and encryption of d are not in it.
+func (d *GhlighDoc) Flatten(out string, keepAnnots bool) error {
+	var annots AnnotsMap
+	if keepAnnots {
+		annots = d.GetAnnotsBuffer()
+		if _, err := d.RemoveAnnots(annots); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.data != nil {
+		return ErrReadOnly
+	}
+
+	info, err := os.Stat(d.Path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(out)
+	flatName, err := tempPDF(dir)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(flatName)
+
+	if err := d.doc.RenderToPDF(flatName); err != nil {
+		return err
+	}
+
+	result := flatName
+	if len(annots) > 0 {
+		result, err = tempPDF(dir)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(result)
+
+		if err := copyAnnots(flatName, result, annots); err != nil {
+			return err
+		}
+	}
+
+	if err := syncFile(result, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return renameSynced(result, out)
+}
+
+// tempPDF creates an empty temporary pdf in dir and returns its name
+func tempPDF(dir string) (string, error) {
+	tempFile, err := os.CreateTemp(dir, TempPrefix+"*.pdf")
+	if err != nil {
+		return "", err
+	}
+	name := tempFile.Name()
+	tempFile.Close()
+	return name, nil
+}
+
+// copyAnnots writes to dst the pdf at src with annots added
+func copyAnnots(src string, dst string, annots AnnotsMap) error {
+	doc, err := Open(src)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+
+	if _, err := doc.Import(annots, ImportOptions{Force: true}); err != nil {
+		return err
+	}
+
+	ok, err := doc.doc.Save(dst)
+	if !ok && err == nil {
+		err = fmt.Errorf("could not save %s", dst)
+	}
+	return err
+}
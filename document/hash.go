@@ -2,6 +2,7 @@ package document
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"reflect"
@@ -20,6 +21,34 @@ import (
 
 var ghlighKey = []byte("ghligh-pdf-doc")
 
+// HashMode selects how a document is identified between export and import
+type HashMode string
+
+const (
+	// HashText hashes the text of the first and last pages (see HashDoc),
+	// copies of a paper with different bytes but the same text match
+	HashText HashMode = "text"
+	// HashBytes hashes the whole file, only byte identical copies match
+	HashBytes HashMode = "bytes"
+)
+
+// HashModes lists every supported hash mode
+var HashModes = []HashMode{HashText, HashBytes}
+
+// ParseHashMode validates s, "" is the default HashText so that exports
+// made before hash modes existed keep matching
+func ParseHashMode(s string) (HashMode, error) {
+	if s == "" {
+		return HashText, nil
+	}
+	for _, mode := range HashModes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown hash mode %q, valid modes are %v", s, HashModes)
+}
+
 var bufPool = sync.Pool{
 	New: func() interface{} {
 		return make([]byte, 0, os.Getpagesize())
@@ -89,6 +118,39 @@ func (d *GhlighDoc) HashDoc() string {
 	return fmt.Sprintf("%x", hmacHash.Sum(nil))
 }
 
+// HashDocMode is like HashDoc but lets the caller choose the hash mode
+func (d *GhlighDoc) HashDocMode(mode HashMode) (string, error) {
+	switch mode {
+	case HashText, "":
+		return d.HashDoc(), nil
+	case HashBytes:
+		return d.hashBytes()
+	default:
+		return "", fmt.Errorf("unknown hash mode %q", mode)
+	}
+}
+
+func (d *GhlighDoc) hashBytes() (string, error) {
+	if d.data != nil {
+		hmacHash := hmac.New(sha256.New, ghlighKey)
+		hmacHash.Write(d.data)
+		return fmt.Sprintf("%x", hmacHash.Sum(nil)), nil
+	}
+
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hmacHash := hmac.New(sha256.New, ghlighKey)
+	if _, err := io.Copy(hmacHash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hmacHash.Sum(nil)), nil
+}
+
 func rectToBytes(r *poppler.Rectangle) []byte {
 	size := int(unsafe.Sizeof(*r))
 
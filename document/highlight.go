@@ -0,0 +1,80 @@
+package document
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// HighlightText adds a highlight over every occurrence of query on the
+// page of index page, ignoring case, occurrences can span lines. The ones
+// already highlighted the same way are skipped, it returns how many
+// highlights were added.
+func (d *GhlighDoc) HighlightText(page int, query string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if strings.TrimSpace(query) == "" {
+		return 0, fmt.Errorf("nothing to search for")
+	}
+
+	nPages := d.doc.GetNPages()
+	if page < 0 || page >= nPages {
+		return 0, fmt.Errorf("page %d out of range, document has %d pages", page+1, nPages)
+	}
+
+	p := d.doc.GetPage(page)
+	defer p.Close()
+
+	ids := pageAnnotIDs(p, page)
+	added := 0
+	for _, rects := range p.FindText(query, false) {
+		annot := highlightOver(rects)
+
+		id := AnnotID(page, annot)
+		if ids[id] {
+			continue
+		}
+
+		a, err := d.jsonToAnnot(annot)
+		if err != nil {
+			return added, err
+		}
+		p.AddAnnot(*a)
+		ids[id] = true
+		added++
+	}
+	return added, nil
+}
+
+// highlightOver returns a highlight covering rects, one quad each
+func highlightOver(rects []poppler.Rectangle) AnnotJSON {
+	annot := AnnotJSON{
+		Type:    poppler.AnnotHighlight,
+		Subtype: markupSubtypes[poppler.AnnotHighlight],
+		Flags:   poppler.AnnotFlagPrint,
+	}
+
+	for i, r := range rects {
+		// quads go top left, top right, bottom left, bottom right and in
+		// pdf coordinates the top is the higher y
+		annot.Quads = append(annot.Quads, poppler.Quad{
+			P1: poppler.Point{X: r.X1, Y: r.Y2},
+			P2: poppler.Point{X: r.X2, Y: r.Y2},
+			P3: poppler.Point{X: r.X1, Y: r.Y1},
+			P4: poppler.Point{X: r.X2, Y: r.Y1},
+		})
+
+		if i == 0 {
+			annot.Rect = r
+			continue
+		}
+		annot.Rect.X1 = math.Min(annot.Rect.X1, r.X1)
+		annot.Rect.Y1 = math.Min(annot.Rect.Y1, r.Y1)
+		annot.Rect.X2 = math.Max(annot.Rect.X2, r.X2)
+		annot.Rect.Y2 = math.Max(annot.Rect.Y2, r.Y2)
+	}
+	return annot
+}
@@ -0,0 +1,32 @@
+package document
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// AnnotID returns a stable id for an annotation on page (from 0): a hash
+// of the page, the subtype and the quad points, or the rectangle for
+// annotations without them (free text). Coordinates are rounded to 1/100
+// of a point since saving the pdf can change the last digits.
+func AnnotID(page int, a AnnotJSON) string {
+	subtype := a.Subtype
+	if t, err := subtypeToType(a.Subtype); err == nil {
+		subtype = markupSubtypes[t]
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s", page, subtype)
+	for _, q := range a.Quads {
+		for _, p := range []poppler.Point{q.P1, q.P2, q.P3, q.P4} {
+			fmt.Fprintf(h, ":%.2f,%.2f", p.X, p.Y)
+		}
+	}
+	if len(a.Quads) == 0 {
+		r := a.Rect
+		fmt.Fprintf(h, ":%.2f,%.2f,%.2f,%.2f", r.X1, r.Y1, r.X2, r.Y2)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:16])
+}
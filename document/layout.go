@@ -0,0 +1,45 @@
+package document
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// page sizes closer than this, in pdf points, are the same size
+const pageSizeTolerance = 0.5
+
+// LayoutChange tells why d doesn't look like the document exported with
+// pageCount pages and the given sizes of its annotated pages, "" when they
+// can't be told apart. Only a hash match tells that the content is the
+// same, this catches the documents edited so much that annotations would
+// land in the wrong place. A pageCount of 0 or missing sizes (older
+// exports) are not compared.
+func (d *GhlighDoc) LayoutChange(pageCount int, sizes map[int]PageSize) string {
+	n := d.doc.GetNPages()
+	if pageCount > 0 && pageCount != n {
+		return fmt.Sprintf("%d pages instead of %d", n, pageCount)
+	}
+
+	pages := make([]int, 0, len(sizes))
+	for page := range sizes {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+
+	for _, i := range pages {
+		if i >= n {
+			return fmt.Sprintf("page %d is missing", i+1)
+		}
+
+		page := d.doc.GetPage(i)
+		width, height := page.Size()
+		page.Close()
+
+		want := sizes[i]
+		if math.Abs(width-want.Width) > pageSizeTolerance || math.Abs(height-want.Height) > pageSizeTolerance {
+			return fmt.Sprintf("page %d is %.0fx%.0f instead of %.0fx%.0f", i+1, width, height, want.Width, want.Height)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package document
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sortedPages returns the page indexes of am in ascending order
+func (am AnnotsMap) sortedPages() []int {
+	var pages []int
+	for page := range am {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// oneLine joins the lines of text so it fits in a markdown list item
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// ExportMarkdown writes the annotations of the document as markdown: a
+// "## Page N" heading for every annotated page followed by one bullet per
+// annotation, with its note quoted underneath.
+//
+// d.AnnotsBuffer is used when already filled (it must contain the text, see
+// GetAnnotsText), otherwise annotations are read from the document.
+func (d *GhlighDoc) ExportMarkdown(w io.Writer) error {
+	annots := d.AnnotsBuffer
+	if annots == nil {
+		annots = d.GetAnnotsText()
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# %s\n", filepath.Base(d.Path))
+	for _, page := range annots.sortedPages() {
+		fmt.Fprintf(bw, "\n## Page %d\n\n", page+1)
+		for _, annot := range annots[page] {
+			fmt.Fprintf(bw, "- %s\n", oneLine(annot.Text))
+			if annot.Contents != "" {
+				fmt.Fprintf(bw, "  > %s\n", oneLine(annot.Contents))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
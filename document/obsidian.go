@@ -0,0 +1,155 @@
+package document
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// obsidianColor is a highlighter color and the callout type its
+// annotations are written with
+type obsidianColor struct {
+	name    string
+	r, g, b int
+	callout string
+}
+
+// the usual highlighter colors of pdf viewers, annotations take the
+// nearest one
+var obsidianColors = []obsidianColor{
+	{"yellow", 0xff, 0xff, 0x00, "quote"},
+	{"red", 0xff, 0x00, 0x00, "danger"},
+	{"green", 0x00, 0xff, 0x00, "success"},
+	{"blue", 0x00, 0x00, 0xff, "info"},
+	{"cyan", 0x00, 0xff, 0xff, "tip"},
+	{"orange", 0xff, 0xa5, 0x00, "warning"},
+	{"purple", 0x80, 0x00, 0x80, "example"},
+	{"pink", 0xff, 0x69, 0xb4, "question"},
+	{"gray", 0x80, 0x80, 0x80, "note"},
+	{"black", 0x00, 0x00, 0x00, "note"},
+}
+
+// nearestObsidianColor returns the entry of obsidianColors closest to c,
+// yellow for annotations without a color (the default of import)
+func nearestObsidianColor(c *poppler.Color) obsidianColor {
+	if c == nil {
+		return obsidianColors[0]
+	}
+
+	best, bestDist := obsidianColors[0], -1
+	for _, oc := range obsidianColors {
+		// poppler colors are 16 bits per channel
+		dr, dg, db := c.R/0x101-oc.r, c.G/0x101-oc.g, c.B/0x101-oc.b
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = oc, dist
+		}
+	}
+	return best
+}
+
+// yamlString quotes s as a yaml double quoted scalar, whose escapes are a
+// superset of go's
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
+
+// quoteLines prefixes every line of text with "> ", the lines of a callout
+func quoteLines(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+strings.TrimSpace(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ExportObsidian writes the annotations of the document as an Obsidian
+// note: yaml frontmatter with the title, author, source file and tags, a
+// heading per annotated page linking to that page of the pdf, and a
+// callout per annotation whose type and #color/ tag follow its color.
+// Notes are written inside the callout, below the highlighted text.
+//
+// Like ExportMarkdown, d.AnnotsBuffer is used when already filled.
+func (d *GhlighDoc) ExportObsidian(w io.Writer) error {
+	annots := d.AnnotsBuffer
+	if annots == nil {
+		annots = d.GetAnnotsText()
+	}
+
+	title := d.Title
+	if title == "" {
+		title = FileTitle(d.Path)
+	}
+	pdf := filepath.Base(d.Path)
+
+	colorTags := make(map[string]bool)
+	for _, pageAnnots := range annots {
+		for _, annot := range pageAnnots {
+			colorTags["color/"+nearestObsidianColor(annot.Color).name] = true
+		}
+	}
+	tags := []string{"highlights"}
+	var sortedColors []string
+	for tag := range colorTags {
+		sortedColors = append(sortedColors, tag)
+	}
+	sort.Strings(sortedColors)
+	tags = append(tags, sortedColors...)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "---\n")
+	fmt.Fprintf(bw, "title: %s\n", yamlString(title))
+	if d.Author != "" {
+		fmt.Fprintf(bw, "author: %s\n", yamlString(d.Author))
+	}
+	fmt.Fprintf(bw, "source: %s\n", yamlString(filepath.ToSlash(d.Path)))
+	if d.PageCount > 0 {
+		fmt.Fprintf(bw, "pages: %d\n", d.PageCount)
+	}
+	if d.HashBuffer != "" {
+		fmt.Fprintf(bw, "hash: %s\n", yamlString(d.HashBuffer))
+	}
+	fmt.Fprintf(bw, "tags:\n")
+	for _, tag := range tags {
+		fmt.Fprintf(bw, "  - %s\n", yamlString(tag))
+	}
+	fmt.Fprintf(bw, "---\n\n")
+
+	fmt.Fprintf(bw, "# %s\n", title)
+	for _, page := range annots.sortedPages() {
+		fmt.Fprintf(bw, "\n## [[%s#page=%d|Page %d]]\n", pdf, page+1, page+1)
+		for _, annot := range annots[page] {
+			color := nearestObsidianColor(annot.Color)
+			subtype := annot.Subtype
+			if subtype == "" {
+				subtype = markupSubtypes[poppler.AnnotHighlight]
+			}
+
+			fmt.Fprintf(bw, "\n> [!%s] %s #color/%s\n", color.callout, subtype, color.name)
+			text := strings.TrimSpace(annot.Text)
+			note := strings.TrimSpace(annot.Contents)
+			// free text annotations only have their own text
+			if text == "" {
+				text, note = note, ""
+			}
+			if text != "" {
+				fmt.Fprintf(bw, "%s\n", quoteLines(oneLine(text)))
+			}
+			if note != "" {
+				if text != "" {
+					fmt.Fprintf(bw, ">\n")
+				}
+				fmt.Fprintf(bw, "%s\n", quoteLines(note))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package document
+
+import (
+	"os"
+	"strings"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// OCRFunc recognizes the text of the png image at path
+type OCRFunc func(path string) (string, error)
+
+// pixels per point of the images given to the OCR, 300 dpi
+const ocrScale = 300.0 / 72.0
+
+// SetOCR makes GetAnnotsText recognize the text of the annotations with
+// ocr on pages without a text layer (scanned pages), nil turns it off
+func (d *GhlighDoc) SetOCR(ocr OCRFunc) {
+	d.ocr = ocr
+}
+
+// hasTextLayer reports whether page has any text poppler can extract
+func hasTextLayer(page *poppler.Page) bool {
+	return strings.TrimSpace(page.Text()) != ""
+}
+
+// TextLayerPages returns how many pages of d have a text layer, the text
+// of annotations on the others can only be recovered with SetOCR
+func (d *GhlighDoc) TextLayerPages() int {
+	n := 0
+	for i := 0; i < d.doc.GetNPages(); i++ {
+		page := d.doc.GetPage(i)
+		if hasTextLayer(page) {
+			n++
+		}
+		page.Close()
+	}
+	return n
+}
+
+// ocrAnnotText renders the area of annot and returns the text recognized
+// in it by d.ocr, errors only cost the text of the annotation
+func (d *GhlighDoc) ocrAnnotText(page *poppler.Page, annot poppler.Annot) string {
+	image, err := os.CreateTemp("", "ghligh_ocr_*.png")
+	if err != nil {
+		return ""
+	}
+	image.Close()
+	defer os.Remove(image.Name())
+
+	if err := page.RenderAnnotPNG(annot, image.Name(), ocrScale); err != nil {
+		return ""
+	}
+
+	text, err := d.ocr(image.Name())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,108 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// buildPDF returns a one page pdf of width x height points drawing content,
+// with the annotation dictionaries annots
+func buildPDF(width, height float64, content string, annots []string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	}
+	refs := ""
+	for i := range annots {
+		refs += fmt.Sprintf(" %d 0 R", 5+i)
+	}
+	objects = append(objects,
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Contents 4 0 R /Annots [%s] >>", width, height, refs),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	)
+	objects = append(objects, annots...)
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func highlightAt(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("<< /Type /Annot /Subtype /Highlight /Rect [%g %g %g %g] /QuadPoints [%g %g %g %g %g %g %g %g] /C [1 1 0] >>",
+		x1, y1, x2, y2, x1, y2, x2, y2, x1, y1, x2, y1)
+}
+
+// TestOCRCrop checks that the image given to the OCR is the area under
+// each highlight: a scanned page (no text layer) has a red band near its
+// top and a blue one near its bottom, each under a highlight
+func TestOCRCrop(t *testing.T) {
+	const width, height = 200, 400
+	content := "1 0 0 rg 20 350 160 30 re f\n0 0 1 rg 20 20 160 30 re f"
+	data := buildPDF(width, height, content, []string{
+		highlightAt(20, 350, 180, 380),
+		highlightAt(20, 20, 180, 50),
+	})
+
+	doc, err := Load(data, "scanned.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	doc.SetOCR(func(path string) (string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		img, err := png.Decode(f)
+		if err != nil {
+			return "", err
+		}
+
+		// both highlights are 160 points wide
+		bounds := img.Bounds()
+		areaWidth := 160.0
+		if want := int(areaWidth * ocrScale); bounds.Dx() < want-1 || bounds.Dx() > want+1 {
+			t.Errorf("image width = %d, want %d", bounds.Dx(), want)
+		}
+		r, g, b, _ := img.At(bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+bounds.Dy()/2).RGBA()
+		switch {
+		case r > 0xc000 && g < 0x4000 && b < 0x4000:
+			return "red", nil
+		case r < 0x4000 && g < 0x4000 && b > 0xc000:
+			return "blue", nil
+		case r > 0xc000 && g > 0xc000 && b > 0xc000:
+			return "white", nil
+		}
+		return fmt.Sprintf("rgb(%d, %d, %d)", r>>8, g>>8, b>>8), nil
+	})
+
+	annots := doc.GetAnnotsText()[0]
+	if len(annots) != 2 {
+		t.Fatalf("got %d annotations, want 2", len(annots))
+	}
+	for _, annot := range annots {
+		want := "blue"
+		if annot.Rect.Y1 > height/2 {
+			want = "red"
+		}
+		if annot.Text != want {
+			t.Errorf("highlight at y %v-%v: ocr read %q, want %q", annot.Rect.Y1, annot.Rect.Y2, annot.Text, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package document
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// readwiseHighlight is a highlight as expected by the readwise import api
+// (POST https://readwise.io/api/v2/highlights/)
+type readwiseHighlight struct {
+	Text          string `json:"text"`
+	Title         string `json:"title"`
+	Author        string `json:"author,omitempty"`
+	SourceType    string `json:"source_type"`
+	Category      string `json:"category"`
+	Note          string `json:"note,omitempty"`
+	Location      int    `json:"location"`
+	LocationType  string `json:"location_type"`
+	HighlightedAt string `json:"highlighted_at,omitempty"`
+}
+
+type readwiseImport struct {
+	Highlights []readwiseHighlight `json:"highlights"`
+}
+
+// readwiseTitle is the title readwise groups the highlights of d under
+func (d *GhlighDoc) readwiseTitle() string {
+	if d.Title != "" {
+		return d.Title
+	}
+	return FileTitle(d.Path)
+}
+
+// ExportReadwise writes the annotations of docs as the body of a readwise
+// highlights import, the page (from 1) is the location. The AnnotsBuffer of
+// every document must be filled with GetAnnotsText, annotations without
+// text are left out since readwise refuses them.
+func ExportReadwise(w io.Writer, docs []GhlighDoc, indent bool) error {
+	body := readwiseImport{Highlights: []readwiseHighlight{}}
+
+	for i := range docs {
+		annots := docs[i].AnnotsBuffer
+		for _, page := range annots.sortedPages() {
+			for _, annot := range annots[page] {
+				text := strings.TrimSpace(annot.Text)
+				if text == "" {
+					continue
+				}
+
+				h := readwiseHighlight{
+					Text:         text,
+					Title:        docs[i].readwiseTitle(),
+					Author:       docs[i].Author,
+					SourceType:   "ghligh",
+					Category:     "books",
+					Note:         annot.Contents,
+					Location:     page + 1,
+					LocationType: "page",
+				}
+				if annot.Created != "" {
+					h.HighlightedAt = annot.Created + "T00:00:00Z"
+				}
+				body.Highlights = append(body.Highlights, h)
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	if indent {
+		enc.SetIndent("", "	")
+	}
+	return enc.Encode(body)
+}
@@ -0,0 +1,47 @@
+package document
+
+import (
+	"fmt"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// RemoveAnnots removes from the document the annotations matching the ones
+// of am (same page, subtype, rectangle and quad points) and returns how
+// many were removed
+func (d *GhlighDoc) RemoveAnnots(am AnnotsMap) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := 0
+
+	nPages := d.doc.GetNPages()
+	for key, annots := range am {
+		if key < 0 || key >= nPages {
+			return removed, fmt.Errorf("page %d out of range, document has %d pages", key+1, nPages)
+		}
+
+		page := d.doc.GetPage(key)
+		var matching []*poppler.Annot
+		for _, annot := range page.GetAnnots() {
+			if !isMarkup(annot.Type()) {
+				continue
+			}
+
+			current := annotToJson(*annot)
+			for _, a := range annots {
+				if AnnotsMatch(current, a) {
+					matching = append(matching, annot)
+					break
+				}
+			}
+		}
+
+		for _, annot := range matching {
+			page.RemoveAnnot(*annot)
+			removed += 1
+		}
+		page.Close()
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,130 @@
+package document
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// schemaEnums restricts the values of some fields, by type and json name
+func schemaEnums() map[string][]any {
+	var modes []any
+	for _, mode := range HashModes {
+		modes = append(modes, string(mode))
+	}
+
+	var subtypeValues []any
+	for _, name := range SubtypeNames() {
+		subtypeValues = append(subtypeValues, name)
+	}
+
+	return map[string][]any{
+		"GhlighDoc.hash_mode": modes,
+		"AnnotJSON.subtype":   subtypeValues,
+	}
+}
+
+// ExportSchema returns a JSON Schema of the json written by ghligh export,
+// it is built from the json tags of GhlighDoc and the types it uses so it
+// follows them when they change
+func ExportSchema() map[string]any {
+	sb := &schemaBuilder{
+		defs:  make(map[string]any),
+		enums: schemaEnums(),
+	}
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "ghligh export",
+		"description": "documents exported by ghligh export, format version " + strconv.Itoa(FormatVersion),
+		"type":        "array",
+		"items":       sb.schema(reflect.TypeOf(GhlighDoc{})),
+		"$defs":       sb.defs,
+	}
+}
+
+type schemaBuilder struct {
+	// named struct types already described, referenced with $ref
+	defs  map[string]any
+	enums map[string][]any
+}
+
+func (sb *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return sb.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": sb.schema(t.Elem())}
+	case reflect.Map:
+		s := map[string]any{
+			"type":                 "object",
+			"additionalProperties": sb.schema(t.Elem()),
+		}
+		// json writes integer keys as strings
+		if t.Key().Kind() != reflect.String {
+			s["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		return s
+	case reflect.Struct:
+		return sb.ref(t)
+	}
+	return map[string]any{}
+}
+
+// ref describes the struct type t in $defs and returns a reference to it
+func (sb *schemaBuilder) ref(t reflect.Type) map[string]any {
+	ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+	if _, ok := sb.defs[t.Name()]; ok {
+		return ref
+	}
+	// placeholder so that recursive types terminate
+	sb.defs[t.Name()] = nil
+
+	properties := make(map[string]any)
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := sb.schema(f.Type)
+		if values, ok := sb.enums[t.Name()+"."+name]; ok {
+			s["enum"] = values
+		}
+		properties[name] = s
+
+		// omitempty never omits a struct
+		omitted := strings.Contains(","+opts+",", ",omitempty,") && f.Type.Kind() != reflect.Struct
+		if !omitted {
+			required = append(required, name)
+		}
+	}
+
+	def := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		def["required"] = required
+	}
+	sb.defs[t.Name()] = def
+	return ref
+}
@@ -0,0 +1,237 @@
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// populatedDoc has every exported field set, TestPopulatedDoc makes sure
+// it stays that way when fields are added
+func populatedDoc() GhlighDoc {
+	opacity := 0.5
+	borderWidth := 2.0
+	return GhlighDoc{
+		Version:    FormatVersion,
+		Path:       "papers/foo.pdf",
+		HashBuffer: "0123456789abcdef",
+		HashMode:   HashBytes,
+		Title:      "Foo",
+		Author:     "Bar",
+		PageCount:  12,
+		Archive:    "papers.zip",
+		AnnotsBuffer: AnnotsMap{
+			0: {{
+				ID:          "a1b2c3",
+				Type:        poppler.AnnotHighlight,
+				Subtype:     "Highlight",
+				Index:       1,
+				Date:        "D:20250102150405Z",
+				Rect:        poppler.Rectangle{X1: 10, Y1: 20, X2: 110, Y2: 32},
+				Color:       &poppler.Color{R: 0xffff, G: 0xd700, B: 0x4000},
+				Opacity:     &opacity,
+				BorderWidth: &borderWidth,
+				Name:        "annot-1",
+				Contents:    "a note",
+				Author:      "Bar",
+				Created:     "D:20250101120000Z",
+				Subject:     "Highlight",
+				Flags:       poppler.AnnotFlagPrint,
+				Quads: []poppler.Quad{{
+					P1: poppler.Point{X: 10, Y: 32},
+					P2: poppler.Point{X: 110, Y: 32},
+					P3: poppler.Point{X: 10, Y: 20},
+					P4: poppler.Point{X: 110, Y: 20},
+				}},
+				Popup:    &AnnotPopup{Rect: poppler.Rectangle{X1: 120, Y1: 20, X2: 220, Y2: 80}, Open: true},
+				Font:     "Helvetica",
+				FontSize: 12,
+				Text:     "highlighted text",
+			}},
+		},
+		PageSizes:  map[int]PageSize{0: {Width: 595, Height: 842}},
+		PageErrors: []PageError{{Page: 3, Error: "broken page"}},
+	}
+}
+
+func TestPopulatedDoc(t *testing.T) {
+	checkPopulated(t, "GhlighDoc", reflect.ValueOf(populatedDoc()))
+}
+
+// checkPopulated fails for every exported field of v, recursively, that
+// has its zero value and so wouldn't be checked against the schema
+func checkPopulated(t *testing.T, path string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			t.Errorf("%s is nil", path)
+			return
+		}
+		checkPopulated(t, path, v.Elem())
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			t.Errorf("%s is empty", path)
+			return
+		}
+		if v.Kind() == reflect.Slice {
+			checkPopulated(t, path+"[0]", v.Index(0))
+		} else {
+			iter := v.MapRange()
+			iter.Next()
+			checkPopulated(t, fmt.Sprintf("%s[%v]", path, iter.Key()), iter.Value())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+			checkPopulated(t, path+"."+f.Name, v.Field(i))
+		}
+	default:
+		if v.IsZero() {
+			t.Errorf("%s is not set", path)
+		}
+	}
+}
+
+// decoded returns v as encoding/json decodes it into an any, the way a
+// client sees it
+func decoded(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d any
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// validateExport returns the errors of validating docs against ExportSchema
+func validateExport(t *testing.T, docs []GhlighDoc) []string {
+	schema := decoded(t, ExportSchema()).(map[string]any)
+	v := &validator{defs: schema["$defs"].(map[string]any)}
+	v.validate("$", decoded(t, docs), schema)
+	return v.errors
+}
+
+func TestExportSchema(t *testing.T) {
+	for _, err := range validateExport(t, []GhlighDoc{populatedDoc()}) {
+		t.Error(err)
+	}
+}
+
+func TestExportSchemaEnums(t *testing.T) {
+	doc := populatedDoc()
+	doc.HashMode = "md5"
+	doc.AnnotsBuffer[0][0].Subtype = "Circle"
+
+	if errs := validateExport(t, []GhlighDoc{doc}); len(errs) != 2 {
+		t.Errorf("got errors %v, want the hash mode and the subtype", errs)
+	}
+}
+
+// validator checks json values against the parts of JSON Schema used by
+// ExportSchema. Unlike JSON Schema it also refuses properties the schema
+// doesn't describe, so that a field missing from the schema fails.
+type validator struct {
+	defs   map[string]any
+	errors []string
+}
+
+func (v *validator) fail(path string, format string, args ...any) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(path string, value any, schema map[string]any) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		def, ok := v.defs[name].(map[string]any)
+		if !ok {
+			v.fail(path, "unknown $ref %s", ref)
+			return
+		}
+		schema = def
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == value {
+				found = true
+			}
+		}
+		if !found {
+			v.fail(path, "%v is not one of %v", value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(path, "%v is not an object", value)
+			return
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		for key, val := range obj {
+			if names, ok := schema["propertyNames"].(map[string]any); ok {
+				if !regexp.MustCompile(names["pattern"].(string)).MatchString(key) {
+					v.fail(path, "property name %q doesn't match %v", key, names["pattern"])
+				}
+			}
+			switch {
+			case properties[key] != nil:
+				v.validate(path+"."+key, val, properties[key].(map[string]any))
+			case additional != nil:
+				v.validate(path+"."+key, val, additional)
+			default:
+				v.fail(path, "property %q is not in the schema", key)
+			}
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				v.fail(path, "required property %q is missing", name)
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			v.fail(path, "%v is not an array", value)
+			return
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), item, items)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			v.fail(path, "%v is not a string", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "%v is not a boolean", value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			v.fail(path, "%v is not a number", value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			v.fail(path, "%v is not an integer", value)
+		}
+	case nil:
+	default:
+		v.fail(path, "unknown type %v", schema["type"])
+	}
+}
@@ -18,13 +18,14 @@ func unmarshallHighlights(jsonData string) (AnnotsMap, error) {
 	return annotsMap, err
 }
 
-func isInPage(a *poppler.Annot, p *poppler.Page) bool {
-	annots := p.GetAnnots()
-	for _, annot := range annots {
-		if popplerAnnotsMatch(a, annot) {
-			return true
+// pageAnnotIDs returns the ids of the markup annotations of p, page is its
+// index
+func pageAnnotIDs(p *poppler.Page, page int) map[string]bool {
+	ids := make(map[string]bool)
+	for _, annot := range p.GetAnnots() {
+		if isMarkup(annot.Type()) {
+			ids[AnnotID(page, annotToJson(*annot))] = true
 		}
 	}
-
-	return false
+	return ids
 }
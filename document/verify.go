@@ -0,0 +1,122 @@
+package document
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/prepuzio/ghligh/go-poppler"
+)
+
+// Verify checks that d, read from an export, is consistent without
+// opening any pdf: a well formed hash, supported subtypes, annotation ids
+// matching their annotation and unique, quad points that are not empty and
+// lie inside of their rectangle. Every problem found is returned, nil if
+// there are none.
+func (d *GhlighDoc) Verify() []string {
+	var problems []string
+	report := func(format string, a ...any) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if d.HashBuffer == "" {
+		report("missing hash")
+	} else if !isHexHash(d.HashBuffer) {
+		report("hash %q is not a hex sha256", d.HashBuffer)
+	}
+	if _, err := ParseHashMode(string(d.HashMode)); err != nil {
+		report("%v", err)
+	}
+	if d.Version > FormatVersion || d.Version < 0 {
+		report("unsupported format version %d", d.Version)
+	}
+
+	pages := make([]int, 0, len(d.AnnotsBuffer))
+	for page := range d.AnnotsBuffer {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+
+	ids := make(map[string]int)
+	for _, page := range pages {
+		if page < 0 || (d.PageCount > 0 && page >= d.PageCount) {
+			report("page %d is outside of the %d pages of the document", page+1, d.PageCount)
+		}
+
+		for i, annot := range d.AnnotsBuffer[page] {
+			where := fmt.Sprintf("page %d annotation %d", page+1, i+1)
+
+			t, err := subtypeToType(annot.Subtype)
+			if err != nil {
+				report("%s: %v", where, err)
+			}
+			if len(annot.Quads) == 0 && isTextMarkup(t) {
+				report("%s: no quad points", where)
+			}
+			for j, q := range annot.Quads {
+				bounds := quadBounds(q)
+				if bounds.X1 == bounds.X2 || bounds.Y1 == bounds.Y2 {
+					report("%s: quad %d is empty", where, j+1)
+				} else if annot.Rect != (poppler.Rectangle{}) && !overlaps(bounds, annot.Rect) {
+					report("%s: quad %d is outside of the annotation rect", where, j+1)
+				}
+			}
+			if annot.Opacity != nil && (*annot.Opacity < 0 || *annot.Opacity > 1) {
+				report("%s: opacity %v is not between 0 and 1", where, *annot.Opacity)
+			}
+			if annot.BorderWidth != nil && *annot.BorderWidth < 0 {
+				report("%s: negative border width %v", where, *annot.BorderWidth)
+			}
+
+			if annot.ID == "" {
+				continue
+			}
+			if id := AnnotID(page, annot); id != annot.ID {
+				report("%s: id %s doesn't match the annotation, expected %s", where, annot.ID, id)
+			}
+			if first, ok := ids[annot.ID]; ok {
+				report("%s: duplicate id %s, also used on page %d", where, annot.ID, first+1)
+			} else {
+				ids[annot.ID] = page
+			}
+		}
+	}
+
+	return problems
+}
+
+// isHexHash reports whether s looks like a hash written by ghligh, the hex
+// form of a sha256
+func isHexHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// quads are compared with their rect with this tolerance, in pdf points
+const quadTolerance = 1.0
+
+// quadBounds returns the bounding box of q
+func quadBounds(q poppler.Quad) poppler.Rectangle {
+	return poppler.Rectangle{
+		X1: math.Min(math.Min(q.P1.X, q.P2.X), math.Min(q.P3.X, q.P4.X)),
+		Y1: math.Min(math.Min(q.P1.Y, q.P2.Y), math.Min(q.P3.Y, q.P4.Y)),
+		X2: math.Max(math.Max(q.P1.X, q.P2.X), math.Max(q.P3.X, q.P4.X)),
+		Y2: math.Max(math.Max(q.P1.Y, q.P2.Y), math.Max(q.P3.Y, q.P4.Y)),
+	}
+}
+
+// overlaps reports whether a, a bounding box from quadBounds, and b, whose
+// corners may be in any order, share some area
+func overlaps(a, b poppler.Rectangle) bool {
+	bx1, bx2 := math.Min(b.X1, b.X2), math.Max(b.X1, b.X2)
+	by1, by2 := math.Min(b.Y1, b.Y2), math.Max(b.Y1, b.Y2)
+	return a.X1 < bx2+quadTolerance && a.X2 > bx1-quadTolerance &&
+		a.Y1 < by2+quadTolerance && a.Y2 > by1-quadTolerance
+}
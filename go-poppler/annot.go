@@ -12,9 +12,68 @@ package poppler
 // PopplerAnnotTextMarkup *wrap_POPPLER_ANNOT_TEXT_MARKUP(PopplerAnnot *annot) {
 //	return POPPLER_ANNOT_TEXT_MARKUP(annot);
 // }
+// gboolean wrap_POPPLER_IS_ANNOT_MARKUP(PopplerAnnot *annot){
+//   return POPPLER_IS_ANNOT_MARKUP(annot);
+// }
+// PopplerAnnotMarkup *wrap_POPPLER_ANNOT_MARKUP(PopplerAnnot *annot) {
+//	return POPPLER_ANNOT_MARKUP(annot);
+// }
+// gboolean wrap_POPPLER_IS_ANNOT_FREE_TEXT(PopplerAnnot *annot){
+//   return POPPLER_IS_ANNOT_FREE_TEXT(annot);
+// }
+// PopplerAnnotFreeText *wrap_POPPLER_ANNOT_FREE_TEXT(PopplerAnnot *annot) {
+//	return POPPLER_ANNOT_FREE_TEXT(annot);
+// }
+//
+// /* border widths can be read and written since poppler 21.12 */
+// static gboolean ghligh_annot_get_border_width(PopplerAnnot *annot, double *width) {
+// #if POPPLER_CHECK_VERSION(21, 12, 0)
+//	return poppler_annot_get_border_width(annot, width);
+// #else
+//	return FALSE;
+// #endif
+// }
+// static gboolean ghligh_annot_set_border_width(PopplerAnnot *annot, double width) {
+// #if POPPLER_CHECK_VERSION(21, 12, 0)
+//	poppler_annot_set_border_width(annot, width);
+//	return TRUE;
+// #else
+//	return FALSE;
+// #endif
+// }
+//
+// /* the font of free text annotations is there since poppler 24.03, name
+//  * must be freed with g_free */
+// static gboolean ghligh_free_text_font(PopplerAnnot *annot, char **name, double *size) {
+// #if POPPLER_CHECK_VERSION(24, 3, 0)
+//	PopplerFontDescription *desc = poppler_annot_free_text_get_font_desc(POPPLER_ANNOT_FREE_TEXT(annot));
+//	if (desc == NULL)
+//		return FALSE;
+//	*name = g_strdup(desc->font_name);
+//	*size = desc->size_pt;
+//	poppler_font_description_free(desc);
+//	return TRUE;
+// #else
+//	return FALSE;
+// #endif
+// }
+// static gboolean ghligh_free_text_set_font(PopplerAnnot *annot, const char *name, double size) {
+// #if POPPLER_CHECK_VERSION(24, 3, 0)
+//	PopplerFontDescription *desc = poppler_font_description_new(name);
+//	desc->size_pt = size;
+//	poppler_annot_free_text_set_font_desc(POPPLER_ANNOT_FREE_TEXT(annot), desc);
+//	poppler_font_description_free(desc);
+//	return TRUE;
+// #else
+//	return FALSE;
+// #endif
+// }
 import "C"
 
-import "unsafe"
+import (
+	"fmt"
+	"unsafe"
+)
 //import "github.com/ungerik/go-cairo"
 
 // DEBUG
@@ -124,6 +183,16 @@ func (a *Annot) Color() Color {
 	return color
 }
 
+// HasColor reports whether the annotation has a color (the /C entry)
+func (a *Annot) HasColor() bool {
+	c := C.poppler_annot_get_color(a.am.annot)
+	if c == nil {
+		return false
+	}
+	C.poppler_color_free(c)
+	return true
+}
+
 func (a *Annot) Name() string {
 	cText := C.poppler_annot_get_name(a.am.annot)
 	return C.GoString(cText)
@@ -141,6 +210,195 @@ func (a *Annot) Contents() string {
 	return C.GoString(cText)
 }
 
+func (a *Annot) isMarkup() bool {
+	return C.wrap_POPPLER_IS_ANNOT_MARKUP(a.am.annot) != C.FALSE
+}
+
+// Author returns the label (the /T entry) of markup annotations, usually
+// the name of who made the annotation
+func (a *Annot) Author() string {
+	if !a.isMarkup() {
+		return ""
+	}
+
+	cText := C.poppler_annot_markup_get_label(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot))
+	if cText == nil {
+		return ""
+	}
+	defer C.g_free(C.gpointer(cText))
+
+	return toString(cText)
+}
+
+// Created returns the creation date of markup annotations as YYYY-MM-DD,
+// poppler doesn't expose the time of the day
+func (a *Annot) Created() string {
+	if !a.isMarkup() {
+		return ""
+	}
+
+	d := C.poppler_annot_markup_get_date(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot))
+	if d == nil {
+		return ""
+	}
+	defer C.g_date_free(d)
+
+	if C.g_date_valid(d) == C.FALSE {
+		return ""
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d",
+		int(C.g_date_get_year(d)),
+		int(C.g_date_get_month(d)),
+		int(C.g_date_get_day(d)),
+	)
+}
+
+// Subject returns the subject (the /Subj entry) of markup annotations
+func (a *Annot) Subject() string {
+	if !a.isMarkup() {
+		return ""
+	}
+
+	cText := C.poppler_annot_markup_get_subject(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot))
+	if cText == nil {
+		return ""
+	}
+	defer C.g_free(C.gpointer(cText))
+
+	return toString(cText)
+}
+
+// Popup returns the rectangle of the popup window of markup annotations
+// and whether it is shown open, ok is false if there is no popup
+func (a *Annot) Popup() (r Rectangle, open bool, ok bool) {
+	if !a.isMarkup() {
+		return r, false, false
+	}
+
+	markup := C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot)
+	if C.poppler_annot_markup_has_popup(markup) == C.FALSE {
+		return r, false, false
+	}
+
+	var pr C.PopplerRectangle
+	if C.poppler_annot_markup_get_popup_rectangle(markup, &pr) == C.FALSE {
+		return r, false, false
+	}
+
+	r = Rectangle{
+		X1: float64(pr.x1),
+		Y1: float64(pr.y1),
+		X2: float64(pr.x2),
+		Y2: float64(pr.y2),
+	}
+	return r, toBool(C.poppler_annot_markup_get_popup_is_open(markup)), true
+}
+
+// SetPopup gives markup annotations a popup window at r, shown open if
+// open is set
+func (a *Annot) SetPopup(r Rectangle, open bool) {
+	if !a.isMarkup() {
+		return
+	}
+
+	markup := C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot)
+	pr := C.PopplerRectangle{
+		x1: C.double(r.X1),
+		y1: C.double(r.Y1),
+		x2: C.double(r.X2),
+		y2: C.double(r.Y2),
+	}
+	C.poppler_annot_markup_set_popup(markup, &pr)
+
+	isOpen := C.gboolean(C.FALSE)
+	if open {
+		isOpen = C.TRUE
+	}
+	C.poppler_annot_markup_set_popup_is_open(markup, isOpen)
+}
+
+// Opacity returns the opacity (the /CA entry) of markup annotations, from
+// 0 (invisible) to 1 (opaque, also the value when missing)
+func (a *Annot) Opacity() float64 {
+	if !a.isMarkup() {
+		return 1
+	}
+
+	return float64(C.poppler_annot_markup_get_opacity(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot)))
+}
+
+// SetOpacity sets the opacity (the /CA entry) of markup annotations
+func (a *Annot) SetOpacity(opacity float64) {
+	if !a.isMarkup() {
+		return
+	}
+
+	C.poppler_annot_markup_set_opacity(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot), C.double(opacity))
+}
+
+// BorderWidth returns the width of the border (the /BS or /Border entry),
+// in points, ok is false when the annotation has none and is drawn with
+// the default width of 1, or with a poppler older than 21.12
+func (a *Annot) BorderWidth() (width float64, ok bool) {
+	var w C.double
+	if C.ghligh_annot_get_border_width(a.am.annot, &w) == C.FALSE {
+		return 1, false
+	}
+	return float64(w), true
+}
+
+// SetBorderWidth sets the width of the border, in points, 0 draws none.
+// It returns ErrUnsupported with a poppler older than 21.12.
+func (a *Annot) SetBorderWidth(width float64) error {
+	if C.ghligh_annot_set_border_width(a.am.annot, C.double(width)) == C.FALSE {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// Font returns the font name and size (in points) of free text
+// annotations, ok is false for other annotations, without a font or
+// with a poppler older than 24.03
+func (a *Annot) Font() (name string, size float64, ok bool) {
+	if C.wrap_POPPLER_IS_ANNOT_FREE_TEXT(a.am.annot) == C.FALSE {
+		return "", 0, false
+	}
+
+	var cName *C.char
+	var cSize C.double
+	if C.ghligh_free_text_font(a.am.annot, &cName, &cSize) == C.FALSE {
+		return "", 0, false
+	}
+	defer C.g_free(C.gpointer(unsafe.Pointer(cName)))
+
+	return C.GoString(cName), float64(cSize), true
+}
+
+// SetFont sets the font of free text annotations, name "" keeps the
+// current one (Helvetica if there is none). It returns ErrUnsupported
+// with a poppler older than 24.03.
+func (a *Annot) SetFont(name string, size float64) error {
+	if C.wrap_POPPLER_IS_ANNOT_FREE_TEXT(a.am.annot) == C.FALSE {
+		return nil
+	}
+
+	if name == "" {
+		name, _, _ = a.Font()
+	}
+	if name == "" {
+		name = "Helvetica"
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	if C.ghligh_free_text_set_font(a.am.annot, cName, C.double(size)) == C.FALSE {
+		return ErrUnsupported
+	}
+	return nil
+}
+
 func (a *Annot) Flags() AnnotFlag {
 	f := C.poppler_annot_get_flags(a.am.annot)
 	return AnnotFlag(f)
@@ -187,6 +445,18 @@ func (a *Annot) SetContents(c string){
 	C.poppler_annot_set_contents(a.am.annot, cStr)
 }
 
+// SetAuthor sets the label (the /T entry) of markup annotations
+func (a *Annot) SetAuthor(author string) {
+	if !a.isMarkup() {
+		return
+	}
+
+	cStr := C.CString(author)
+	defer C.free(unsafe.Pointer(cStr))
+
+	C.poppler_annot_markup_set_label(C.wrap_POPPLER_ANNOT_MARKUP(a.am.annot), cStr)
+}
+
 func  (a *Annot) SetFlags(f AnnotFlag){
 	pFlags := C.PopplerAnnotFlag(f)
 
@@ -5,6 +5,15 @@ package poppler
 // #include <stdlib.h>
 // #include <glib.h>
 // #include <unistd.h>
+//
+// /* free text annotations can be made since poppler 24.03 */
+// static PopplerAnnot *ghligh_annot_free_text_new(PopplerDocument *doc, PopplerRectangle *rect) {
+// #if POPPLER_CHECK_VERSION(24, 3, 0)
+//	return poppler_annot_free_text_new(doc, rect);
+// #else
+//	return NULL;
+// #endif
+// }
 import "C"
 
 import (
@@ -66,6 +75,16 @@ func (d *Document) GetPage(i int) (page *Page) {
 	return page
 }
 
+// OpenPage is GetPage returning an error for pages poppler can't parse,
+// GetPage returns a page that can't be used for them
+func (d *Document) OpenPage(i int) (*Page, error) {
+	page := d.GetPage(i)
+	if page.p == nil {
+		return nil, errors.New("page can't be parsed")
+	}
+	return page, nil
+}
+
 func (d *Document) HasAttachments() bool {
 	return toBool(C.poppler_document_has_attachments(d.doc))
 }
@@ -107,6 +126,12 @@ func (d *Document) NewAnnot(t AnnotType, r Rectangle, q []Quad) (Annot, error) {
 		am.annot = C.poppler_annot_text_markup_new_squiggly(d.doc, &pRect, pQuad)
 	case AnnotStrikeOut:
 		am.annot = C.poppler_annot_text_markup_new_strikeout(d.doc, &pRect, pQuad)
+	case AnnotFreeText:
+		am.annot = C.ghligh_annot_free_text_new(d.doc, &pRect)
+		if am.annot == nil {
+			C.poppler_annot_mapping_free(am)
+			return annot, ErrUnsupported
+		}
 	default:
 		C.poppler_annot_mapping_free(am)
 		return annot, errors.New("invalid type for new annotation")
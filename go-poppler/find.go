@@ -0,0 +1,69 @@
+package poppler
+
+// #cgo pkg-config: poppler-glib
+// #include <poppler.h>
+// #include <stdlib.h>
+// #include <glib.h>
+//
+// static void free_rectangles(GList *list) {
+//	g_list_free_full(list, (GDestroyNotify)poppler_rectangle_free);
+// }
+//
+// /* matches spanning more than one line are found since poppler 21.05,
+//  * older ones only find those within a line */
+// static PopplerFindFlags find_multiline(void) {
+// #if POPPLER_CHECK_VERSION(21, 5, 0)
+//	return POPPLER_FIND_MULTILINE;
+// #else
+//	return POPPLER_FIND_DEFAULT;
+// #endif
+// }
+// static gboolean match_continued(PopplerRectangle *r) {
+// #if POPPLER_CHECK_VERSION(21, 5, 0)
+//	return poppler_rectangle_find_get_match_continued(r);
+// #else
+//	return FALSE;
+// #endif
+// }
+import "C"
+
+import "unsafe"
+
+// FindText returns the occurrences of text on the page, each as the
+// rectangles it covers in pdf coordinates (origin at the bottom left), one
+// per line for occurrences spanning more than one line (with a poppler
+// older than 21.05 only occurrences within a line are found). The search
+// ignores case unless caseSensitive is set.
+func (p *Page) FindText(text string, caseSensitive bool) [][]Rectangle {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+
+	options := C.find_multiline()
+	if caseSensitive {
+		options |= C.POPPLER_FIND_CASE_SENSITIVE
+	}
+
+	list := C.poppler_page_find_text_with_options(p.p, (*C.char)(cText), options)
+	defer C.free_rectangles(list)
+
+	var matches [][]Rectangle
+	var current []Rectangle
+	for el := list; el != nil; el = el.next {
+		r := (*C.PopplerRectangle)(el.data)
+		current = append(current, Rectangle{
+			X1: float64(r.x1),
+			Y1: float64(r.y1),
+			X2: float64(r.x2),
+			Y2: float64(r.y2),
+		})
+		// the next rectangle is the following line of the same match
+		if C.match_continued(r) == C.FALSE {
+			matches = append(matches, current)
+			current = nil
+		}
+	}
+	if current != nil {
+		matches = append(matches, current)
+	}
+	return matches
+}
@@ -15,7 +15,19 @@ import (
 
 type poppDoc *C.struct__PopplerDocument
 
+// ErrEncrypted is returned when opening a pdf that needs a password, or
+// with the wrong password
+var ErrEncrypted = errors.New("pdf is encrypted, a password is needed")
+
+// ErrUnsupported is returned by the functions needing a newer poppler than
+// the one ghligh was built with
+var ErrUnsupported = errors.New("not supported by the poppler version ghligh was built with")
+
 func Open(filename string) (doc *Document, err error) {
+	return OpenWithPassword(filename, "")
+}
+
+func OpenWithPassword(filename string, password string) (doc *Document, err error) {
 	filename, err = filepath.Abs(filename)
 	if err != nil {
 		return
@@ -23,11 +35,20 @@ func Open(filename string) (doc *Document, err error) {
 	var e *C.GError
 	cfilename := (*C.gchar)(C.CString(filename))
 	defer C.free(unsafe.Pointer(cfilename))
+	var cpassword *C.char
+	if password != "" {
+		cpassword = C.CString(password)
+		defer C.free(unsafe.Pointer(cpassword))
+	}
 	fn := C.g_filename_to_uri(cfilename, nil, nil)
 	var d poppDoc
-	d = C.poppler_document_new_from_file((*C.char)(fn), nil, &e)
+	d = C.poppler_document_new_from_file((*C.char)(fn), cpassword, &e)
 	if e != nil {
-		err = errors.New(C.GoString((*C.char)(e.message)))
+		if e.domain == C.poppler_error_quark() && e.code == C.POPPLER_ERROR_ENCRYPTED {
+			err = ErrEncrypted
+		} else {
+			err = errors.New(C.GoString((*C.char)(e.message)))
+		}
 	}
 	doc = &Document{
 		doc:                d,
@@ -37,15 +58,30 @@ func Open(filename string) (doc *Document, err error) {
 }
 
 func Load(data []byte) (doc *Document, err error) {
+	return LoadWithPassword(data, "")
+}
+
+// LoadWithPassword is like OpenWithPassword for a pdf held in data
+func LoadWithPassword(data []byte, password string) (doc *Document, err error) {
 	var e *C.GError
 	var d poppDoc
 
+	var cpassword *C.char
+	if password != "" {
+		cpassword = C.CString(password)
+		defer C.free(unsafe.Pointer(cpassword))
+	}
+
 	b := C.g_bytes_new((C.gconstpointer)(unsafe.Pointer(&data[0])), (C.ulong)(len(data)))
 	defer C.g_bytes_unref(b)
 
-	d = C.poppler_document_new_from_bytes(b, nil, &e)
+	d = C.poppler_document_new_from_bytes(b, cpassword, &e)
 	if e != nil {
-		err = errors.New(C.GoString((*C.char)(e.message)))
+		if e.domain == C.poppler_error_quark() && e.code == C.POPPLER_ERROR_ENCRYPTED {
+			err = ErrEncrypted
+		} else {
+			err = errors.New(C.GoString((*C.char)(e.message)))
+		}
 	}
 	doc = &Document{
 		doc: d,
@@ -0,0 +1,91 @@
+package poppler
+
+// #cgo pkg-config: poppler-glib cairo-pdf
+// #include <poppler.h>
+// #include <stdlib.h>
+// #include <cairo.h>
+// #include <cairo-pdf.h>
+import "C"
+
+import (
+	"errors"
+	"path/filepath"
+	"unsafe"
+)
+
+// RenderToPDF draws every page of d, annotations included, into a new pdf
+// at filename. The new pdf has no annotations, their appearance is part of
+// the page content.
+func (d *Document) RenderToPDF(filename string) error {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	cFilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cFilename))
+
+	// every page sets its own size before being drawn
+	surface := C.cairo_pdf_surface_create(cFilename, 1, 1)
+	defer C.cairo_surface_destroy(surface)
+
+	cr := C.cairo_create(surface)
+	defer C.cairo_destroy(cr)
+
+	for i := 0; i < d.GetNPages(); i++ {
+		page := d.GetPage(i)
+		width, height := page.Size()
+		C.cairo_pdf_surface_set_size(surface, C.double(width), C.double(height))
+
+		C.cairo_save(cr)
+		C.poppler_page_render(page.p, cr)
+		C.cairo_restore(cr)
+		C.cairo_show_page(cr)
+
+		page.Close()
+	}
+
+	C.cairo_surface_finish(surface)
+	if status := C.cairo_surface_status(surface); status != C.CAIRO_STATUS_SUCCESS {
+		return errors.New(C.GoString(C.cairo_status_to_string(status)))
+	}
+	return nil
+}
+
+// RenderAnnotPNG draws the area of p under the annotation a, without the
+// annotations, into a png image at filename with scale pixels per point,
+// for instance to recognize the text of scanned pages
+func (p *Page) RenderAnnotPNG(a Annot, filename string, scale float64) error {
+	area := a.am.area
+	width := int((area.x2 - area.x1) * C.double(scale))
+	height := int((area.y2 - area.y1) * C.double(scale))
+	if width < 1 || height < 1 {
+		return errors.New("empty annotation area")
+	}
+
+	cFilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cFilename))
+
+	surface := C.cairo_image_surface_create(C.CAIRO_FORMAT_RGB24, C.int(width), C.int(height))
+	defer C.cairo_surface_destroy(surface)
+
+	cr := C.cairo_create(surface)
+	defer C.cairo_destroy(cr)
+
+	// scanned pages are usually black on white, the surface starts black
+	C.cairo_set_source_rgb(cr, 1, 1, 1)
+	C.cairo_paint(cr)
+
+	// the area is in pdf coordinates, from the bottom left of the page as
+	// shown (rotation applied, like the size), cairo draws that page from
+	// its top left
+	_, pageHeight := p.Size()
+	C.cairo_scale(cr, C.double(scale), C.double(scale))
+	C.cairo_translate(cr, -area.x1, -(C.double(pageHeight) - area.y2))
+	C.poppler_page_render_for_printing_with_options(p.p, cr, C.POPPLER_PRINT_DOCUMENT)
+
+	if status := C.cairo_surface_write_to_png(surface, cFilename); status != C.CAIRO_STATUS_SUCCESS {
+		return errors.New(C.GoString(C.cairo_status_to_string(status)))
+	}
+	return nil
+}